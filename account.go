@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/auth"
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/utils"
+)
+
+const exportPageSize = 500
+
+type DeleteUserRequest struct {
+	Password string `json:"password"`
+}
+
+type DeleteUserResponse struct {
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+// ExportedChirp is a chirp as it appears in a data export. Unlike Chirp it
+// keeps deleted and scheduled chirps and says which is which.
+type ExportedChirp struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Body        string     `json:"body"`
+	ParentID    *uuid.UUID `json:"parent_id,omitempty"`
+	RechirpOfID *uuid.UUID `json:"rechirp_of,omitempty"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ExportedSession describes an active session without its refresh token,
+// so an export can be shared without handing out a login.
+type ExportedSession struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	StartedAt  time.Time `json:"started_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func exportedChirp(chirp database.Chirp) ExportedChirp {
+	exported := ExportedChirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+	}
+	if chirp.ParentID.Valid {
+		exported.ParentID = &chirp.ParentID.UUID
+	}
+	if chirp.RechirpOfID.Valid {
+		exported.RechirpOfID = &chirp.RechirpOfID.UUID
+	}
+	if chirp.PublishAt.Valid {
+		exported.PublishAt = &chirp.PublishAt.Time
+	}
+	if chirp.DeletedAt.Valid {
+		exported.DeletedAt = &chirp.DeletedAt.Time
+	}
+	return exported
+}
+
+func (cfg *apiConfig) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req DeleteUserRequest
+
+	userID := requirePrincipal(r).UserID
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if req.Password == "" {
+		http.Error(w, "Password is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	match, err := auth.CheckPasswordHash(req.Password, user.HashedPassword)
+	if err != nil || !match {
+		http.Error(w, "Password is incorrect", http.StatusForbidden)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	// the purge job removes the user once the grace period is over and
+	// the ON DELETE CASCADE foreign keys take their data with them;
+	// logging in again before then cancels the deletion
+	user, err = qtx.RequestUserDeletion(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = qtx.RevokeAllRefreshTokensForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := DeleteUserResponse{
+		PurgeAfter: user.DeletionRequestedAt.Time.Add(cfg.deletionGrace),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// exportUserHandler streams everything we hold about the user as a single
+// JSON document. Chirps are read and written a page at a time so large
+// accounts don't have to fit in memory.
+func (cfg *apiConfig) exportUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requirePrincipal(r).UserID
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	activeSessions, err := cfg.db.ListSessionsForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	sessions := make([]ExportedSession, len(activeSessions))
+	for i, session := range activeSessions {
+		sessions[i] = ExportedSession{
+			ID:         session.FamilyID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+			StartedAt:  session.StartedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="chirpy-export.json"`)
+	w.WriteHeader(http.StatusOK)
+
+	// the status is already sent, so from here on a failure can only cut
+	// the document short, which leaves it as invalid JSON
+	writeRaw := func(s string) bool {
+		_, err := io.WriteString(w, s)
+		return err == nil
+	}
+	write := func(v any) bool {
+		data, err := json.Marshal(v)
+		if err != nil {
+			log.Println("Error encoding export:", err)
+			return false
+		}
+		_, err = w.Write(data)
+		return err == nil
+	}
+
+	ok := writeRaw(`{"exported_at":`) &&
+		write(time.Now()) &&
+		writeRaw(`,"profile":`) &&
+		write(userResponse(user)) &&
+		writeRaw(`,"sessions":`) &&
+		write(sessions) &&
+		writeRaw(`,"chirps":[`)
+	if !ok {
+		return
+	}
+
+	var cursor *utils.Cursor
+	first := true
+	for {
+		afterCreatedAt, afterID := cursorArgs(cursor)
+
+		chirps, err := cfg.db.ListChirpsForExport(r.Context(), database.ListChirpsForExportParams{
+			UserID:         userID,
+			AfterCreatedAt: afterCreatedAt,
+			AfterID:        afterID,
+			PageSize:       exportPageSize,
+		})
+		if err != nil {
+			log.Println("Error exporting chirps:", err)
+			return
+		}
+
+		for _, chirp := range chirps {
+			if !first && !writeRaw(",") {
+				return
+			}
+			first = false
+			if !write(exportedChirp(chirp)) {
+				return
+			}
+		}
+
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+
+		if len(chirps) < exportPageSize {
+			break
+		}
+		last := chirps[len(chirps)-1]
+		cursor = &utils.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	writeRaw("]}\n")
+}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/utils"
+)
+
+// Roles are ranked: each one can do everything the ones before it can.
+// The first admin has to be promoted in the database:
+//
+//	UPDATE users SET role = 'admin' WHERE email = '...';
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{
+	roleUser:      0,
+	roleModerator: 1,
+	roleAdmin:     2,
+}
+
+const maxSuspensionReasonLength = 500
+
+// AdminUser is the view of an account staff get, with the fields that
+// aren't shown on public profiles.
+type AdminUser struct {
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	Email               string     `json:"email"`
+	Username            string     `json:"username,omitempty"`
+	Role                string     `json:"role"`
+	IsChirpyRed         bool       `json:"is_chirpy_red"`
+	EmailVerified       bool       `json:"email_verified"`
+	SuspendedAt         *time.Time `json:"suspended_at"`
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at"`
+}
+
+type AdminUserPage struct {
+	Users      []AdminUser `json:"users"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+type AuditLogEntry struct {
+	ID           uuid.UUID       `json:"id"`
+	ActorID      uuid.UUID       `json:"actor_id"`
+	Action       string          `json:"action"`
+	TargetUserID *uuid.UUID      `json:"target_user_id"`
+	Details      json.RawMessage `json:"details"`
+	IPAddress    string          `json:"ip_address"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+type AuditLogPage struct {
+	Entries    []AuditLogEntry `json:"entries"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+type SetChirpyRedRequest struct {
+	IsChirpyRed bool `json:"is_chirpy_red"`
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func adminUserResponse(user database.User) AdminUser {
+	resp := AdminUser{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		Email:         user.Email,
+		Username:      user.Username.String,
+		Role:          user.Role,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	}
+	if user.SuspendedAt.Valid {
+		resp.SuspendedAt = &user.SuspendedAt.Time
+	}
+	if user.DeletionRequestedAt.Valid {
+		resp.DeletionRequestedAt = &user.DeletionRequestedAt.Time
+	}
+	return resp
+}
+
+// RequireRole is RequireAuth for staff endpoints. It needs an access token
+// from a login, never an API token, of a user with at least role.
+func (cfg *apiConfig) RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return cfg.RequireAuth(loginOnly, func(w http.ResponseWriter, r *http.Request) {
+		if roleRanks[requirePrincipal(r).Role] < roleRanks[role] {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// recordAudit logs an admin action. It should run in the same transaction
+// as the action so one is never stored without the other.
+func recordAudit(r *http.Request, q *database.Queries, action string, targetUserID uuid.NullUUID, details map[string]any) error {
+	data := []byte("{}")
+	if details != nil {
+		var err error
+		data, err = json.Marshal(details)
+		if err != nil {
+			return err
+		}
+	}
+
+	return q.CreateAuditLogEntry(r.Context(), database.CreateAuditLogEntryParams{
+		ActorID:      requirePrincipal(r).UserID,
+		Action:       action,
+		TargetUserID: targetUserID,
+		Details:      data,
+		IpAddress:    clientIP(r),
+	})
+}
+
+// adminTarget reads the {userID} path value and looks the user up,
+// writing the error response itself when that fails.
+func (cfg *apiConfig) adminTarget(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return database.User{}, false
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return database.User{}, false
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// listUsersHandler lists accounts newest first. `q` matches part of the
+// email or username and `role` filters by role.
+func (cfg *apiConfig) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, cursor, err := parsePageParams(r, utils.CursorKindTime)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	var query, role sql.NullString
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		query = sql.NullString{String: q, Valid: true}
+	}
+	if rawRole := r.URL.Query().Get("role"); rawRole != "" {
+		if _, ok := roleRanks[rawRole]; !ok {
+			http.Error(w, "Unknown role", http.StatusBadRequest)
+			return
+		}
+		role = sql.NullString{String: rawRole, Valid: true}
+	}
+
+	afterCreatedAt, afterID := cursorArgs(cursor)
+	users, err := cfg.db.ListUsers(r.Context(), database.ListUsersParams{
+		Query:          query,
+		Role:           role,
+		AfterCreatedAt: afterCreatedAt,
+		AfterID:        afterID,
+		PageSize:       int32(limit + 1),
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := AdminUserPage{}
+	if len(users) > limit {
+		users = users[:limit]
+		last := users[len(users)-1]
+		resp.NextCursor = utils.EncodeCursor(utils.Cursor{
+			Kind:      utils.CursorKindTime,
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	resp.Users = make([]AdminUser, len(users))
+	for i, user := range users {
+		resp.Users[i] = adminUserResponse(user)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// suspendUserHandler blocks an account from logging in or using any of its
+// tokens until it is unsuspended. Staff can only suspend accounts ranked
+// below their own.
+func (cfg *apiConfig) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req SuspendUserRequest
+
+	target, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" || len(reason) > maxSuspensionReasonLength {
+		http.Error(w, "A reason of up to 500 characters is required", http.StatusBadRequest)
+		return
+	}
+
+	if roleRanks[target.Role] >= roleRanks[requirePrincipal(r).Role] {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := qtx.SuspendUser(r.Context(), target.ID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// access tokens are refused while suspended; revoking the refresh
+	// tokens means unsuspending doesn't bring old sessions back
+	err = qtx.RevokeAllRefreshTokensForUser(r.Context(), target.ID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = recordAudit(r, qtx, "user.suspend", uuid.NullUUID{UUID: target.ID, Valid: true}, map[string]any{
+		"reason": reason,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := adminUserResponse(user)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if roleRanks[target.Role] >= roleRanks[requirePrincipal(r).Role] {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := qtx.UnsuspendUser(r.Context(), target.ID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = recordAudit(r, qtx, "user.unsuspend", uuid.NullUUID{UUID: target.ID, Valid: true}, nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := adminUserResponse(user)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// unlockUserHandler clears the failed login and two-factor counters of an
+// account. Counters for the addresses the attempts came from are kept.
+func (cfg *apiConfig) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.accountLimiter.Reset(r.Context(), accountThrottleKey(target.Email))
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = cfg.accountLimiter.Reset(r.Context(), twoFactorThrottleKey(target.ID))
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = recordAudit(r, cfg.db, "user.unlock", uuid.NullUUID{UUID: target.ID, Valid: true}, nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) setChirpyRedHandler(w http.ResponseWriter, r *http.Request) {
+	var req SetChirpyRedRequest
+
+	target, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := qtx.SetUserChirpyRed(r.Context(), database.SetUserChirpyRedParams{
+		ID:          target.ID,
+		IsChirpyRed: req.IsChirpyRed,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = recordAudit(r, qtx, "user.chirpy_red", uuid.NullUUID{UUID: target.ID, Valid: true}, map[string]any{
+		"is_chirpy_red": req.IsChirpyRed,
+		"was":           target.IsChirpyRed,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := adminUserResponse(user)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var req SetRoleRequest
+
+	target, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := roleRanks[req.Role]; !ok {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+
+	// so the last admin can't demote themselves by accident
+	if target.ID == requirePrincipal(r).UserID {
+		http.Error(w, "You can't change your own role", http.StatusForbidden)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := qtx.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   target.ID,
+		Role: req.Role,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = recordAudit(r, qtx, "user.role", uuid.NullUUID{UUID: target.ID, Valid: true}, map[string]any{
+		"role": req.Role,
+		"was":  target.Role,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := adminUserResponse(user)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// revokeUserSessionsHandler signs a user out everywhere, access tokens
+// included since they are checked against their session.
+func (cfg *apiConfig) revokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.RevokeAllRefreshTokensForUser(r.Context(), target.ID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = recordAudit(r, qtx, "user.sessions.revoke", uuid.NullUUID{UUID: target.ID, Valid: true}, nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getAuditLogHandler lists admin actions newest first, optionally only
+// those taken on the account in `user_id`.
+func (cfg *apiConfig) getAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	limit, cursor, err := parsePageParams(r, utils.CursorKindTime)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	var targetUserID uuid.NullUUID
+	if rawUserID := r.URL.Query().Get("user_id"); rawUserID != "" {
+		userID, err := uuid.Parse(rawUserID)
+		if err != nil {
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+		targetUserID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+
+	afterCreatedAt, afterID := cursorArgs(cursor)
+	entries, err := cfg.db.ListAuditLogEntries(r.Context(), database.ListAuditLogEntriesParams{
+		TargetUserID:   targetUserID,
+		AfterCreatedAt: afterCreatedAt,
+		AfterID:        afterID,
+		PageSize:       int32(limit + 1),
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := AuditLogPage{}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[len(entries)-1]
+		resp.NextCursor = utils.EncodeCursor(utils.Cursor{
+			Kind:      utils.CursorKindTime,
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	resp.Entries = make([]AuditLogEntry, len(entries))
+	for i, entry := range entries {
+		resp.Entries[i] = AuditLogEntry{
+			ID:        entry.ID,
+			ActorID:   entry.ActorID,
+			Action:    entry.Action,
+			Details:   entry.Details,
+			IPAddress: entry.IpAddress,
+			CreatedAt: entry.CreatedAt,
+		}
+		if entry.TargetUserID.Valid {
+			resp.Entries[i].TargetUserID = &entry.TargetUserID.UUID
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/auth"
+	"github.com/kn1ghtm0nster/internal/database"
+)
+
+const maxAPITokenNameLength = 100
+
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays *int     `json:"expires_in_days"`
+}
+
+// APIToken is a personal access token. Token is only set in the response
+// that creates it.
+type APIToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func apiTokenResponse(token database.ApiToken) APIToken {
+	resp := APIToken{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt,
+	}
+	if token.LastUsedAt.Valid {
+		resp.LastUsedAt = &token.LastUsedAt.Time
+	}
+	if token.ExpiresAt.Valid {
+		resp.ExpiresAt = &token.ExpiresAt.Time
+	}
+	return resp
+}
+
+// API tokens are managed with an access token from a login (see the
+// routes), so a leaked API token can't be used to mint more of them.
+func (cfg *apiConfig) createAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPITokenRequest
+
+	userID := requirePrincipal(r).UserID
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxAPITokenNameLength {
+		http.Error(w, "Name must be between 1 and 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	scopes, err := auth.ValidateScopes(req.Scopes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var expiresAt sql.NullTime
+	if req.ExpiresInDays != nil {
+		if *req.ExpiresInDays < 1 {
+			http.Error(w, "expires_in_days must be at least 1", http.StatusBadRequest)
+			return
+		}
+		expiresAt = sql.NullTime{Time: time.Now().AddDate(0, 0, *req.ExpiresInDays), Valid: true}
+	}
+
+	apiToken, err := auth.MakeAPIToken(auth.PersonalAccessTokenPrefix)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	created, err := cfg.db.CreateAPIToken(r.Context(), database.CreateAPITokenParams{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: auth.HashToken(apiToken),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// this is the only time the token is shown
+	resp := apiTokenResponse(created)
+	resp.Token = apiToken
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) getAPITokensHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requirePrincipal(r).UserID
+
+	tokens, err := cfg.db.ListPersonalAccessTokens(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]APIToken, len(tokens))
+	for i, apiToken := range tokens {
+		resp[i] = apiTokenResponse(apiToken)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) revokeAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID := requirePrincipal(r).UserID
+
+	revoked, err := cfg.db.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if revoked == 0 {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/utils"
+)
+
+func TestIsLegacyChirpList(t *testing.T) {
+	tests := []struct {
+		url    string
+		legacy bool
+	}{
+		{"/api/chirps", true},
+		{"/api/chirps?sort=desc", true},
+		{"/api/chirps?author_id=" + uuid.NewString(), true},
+		{"/api/chirps?limit=10", false},
+		{"/api/chirps?cursor=abc", false},
+		{"/api/chirps?limit=", false},
+	}
+
+	for _, tc := range tests {
+		got := isLegacyChirpList(httptest.NewRequest("GET", tc.url, nil))
+		if got != tc.legacy {
+			t.Errorf("isLegacyChirpList(%q) = %v, expected %v", tc.url, got, tc.legacy)
+		}
+	}
+}
+
+// clients written before pagination expect GET /api/chirps to return a
+// bare array
+func TestChirpListBody_LegacyShape(t *testing.T) {
+	page := ChirpPage{
+		Chirps:     []Chirp{{ID: uuid.New(), Body: "hello"}},
+		NextCursor: "next",
+	}
+
+	data, err := json.Marshal(chirpListBody(page, true))
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %v", err)
+	}
+	var chirps []map[string]any
+	if err := json.Unmarshal(data, &chirps); err != nil {
+		t.Fatalf("legacy body %s is not an array: %v", data, err)
+	}
+	if len(chirps) != 1 || chirps[0]["body"] != "hello" {
+		t.Fatalf("legacy body = %s", data)
+	}
+
+	empty, _ := json.Marshal(chirpListBody(ChirpPage{Chirps: []Chirp{}}, true))
+	if string(empty) != "[]" {
+		t.Fatalf("legacy body with no chirps = %s, expected []", empty)
+	}
+
+	data, _ = json.Marshal(chirpListBody(page, false))
+	var paged ChirpPage
+	if err := json.Unmarshal(data, &paged); err != nil || paged.NextCursor != "next" {
+		t.Fatalf("paged body = %s", data)
+	}
+}
+
+// clients that don't page still get a bounded list
+func TestParsePageParams_LegacyDefault(t *testing.T) {
+	limit, cursor, err := parsePageParams(httptest.NewRequest("GET", "/api/chirps", nil), utils.CursorKindTime)
+	if err != nil {
+		t.Fatalf("parsePageParams returned an error: %v", err)
+	}
+	if limit != utils.DefaultPageSize || cursor != nil {
+		t.Fatalf("parsePageParams = %d, %v, expected %d, nil", limit, cursor, utils.DefaultPageSize)
+	}
+}
+
+func TestBuildChirpThread_DeletedChirps(t *testing.T) {
+	now := time.Now()
+	deleted := sql.NullTime{Time: now, Valid: true}
+	root := database.Chirp{ID: uuid.New(), Body: "root"}
+	// deleted, but a reply below it is still live
+	gone := database.Chirp{ID: uuid.New(), Body: "gone", ParentID: uuid.NullUUID{UUID: root.ID, Valid: true}, DeletedAt: deleted}
+	// deleted with nothing live below it
+	leaf := database.Chirp{ID: uuid.New(), Body: "leaf", ParentID: uuid.NullUUID{UUID: root.ID, Valid: true}, DeletedAt: deleted}
+	reply := database.Chirp{ID: uuid.New(), Body: "reply", ParentID: uuid.NullUUID{UUID: gone.ID, Valid: true}}
+
+	rows := []database.GetChirpThreadRow{
+		{Chirp: root, Depth: 0},
+		{Chirp: gone, Depth: 1},
+		{Chirp: leaf, Depth: 1},
+		{Chirp: reply, Depth: 2},
+	}
+	thread := buildChirpThread(rows, []Chirp{chirpResponse(root), chirpResponse(reply)})
+
+	if thread == nil || thread.Body != "root" || len(thread.Replies) != 1 {
+		t.Fatalf("thread = %+v, expected the root with one reply", thread)
+	}
+	placeholder := thread.Replies[0]
+	if !placeholder.Deleted || placeholder.ID != gone.ID || placeholder.Body != "" {
+		t.Fatalf("deleted parent = %+v, expected an empty placeholder", placeholder)
+	}
+	if len(placeholder.Replies) != 1 || placeholder.Replies[0].Body != "reply" {
+		t.Fatalf("placeholder replies = %+v, expected the live reply", placeholder.Replies)
+	}
+}
+
+func TestBuildChirpThread_DeletedRootWithoutReplies(t *testing.T) {
+	root := database.Chirp{ID: uuid.New(), DeletedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+
+	thread := buildChirpThread([]database.GetChirpThreadRow{{Chirp: root, Depth: 0}}, nil)
+	if thread != nil {
+		t.Fatalf("thread = %+v, expected nil", thread)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/utils"
+)
+
+type Follow struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type FollowPage struct {
+	Users      []Follow `json:"users"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) followUserHandler(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID := requirePrincipal(r).UserID
+
+	if followeeID == userID {
+		http.Error(w, "You cannot follow yourself", http.StatusBadRequest)
+		return
+	}
+
+	_, err = cfg.db.GetUserById(r.Context(), followeeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// following someone twice is a no-op
+	_, err = cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID := requirePrincipal(r).UserID
+
+	_, err = cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(w, r, func(userID uuid.UUID, limit int, cursor *utils.Cursor) ([]Follow, error) {
+		afterCreatedAt, afterID := cursorArgs(cursor)
+		follows, err := cfg.db.ListFollowers(r.Context(), database.ListFollowersParams{
+			UserID:         userID,
+			AfterCreatedAt: afterCreatedAt,
+			AfterID:        afterID,
+			PageSize:       int32(limit),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		users := make([]Follow, len(follows))
+		for i, follow := range follows {
+			users[i] = Follow{UserID: follow.FollowerID, FollowedAt: follow.CreatedAt}
+		}
+		return users, nil
+	})
+}
+
+func (cfg *apiConfig) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(w, r, func(userID uuid.UUID, limit int, cursor *utils.Cursor) ([]Follow, error) {
+		afterCreatedAt, afterID := cursorArgs(cursor)
+		follows, err := cfg.db.ListFollowing(r.Context(), database.ListFollowingParams{
+			UserID:         userID,
+			AfterCreatedAt: afterCreatedAt,
+			AfterID:        afterID,
+			PageSize:       int32(limit),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		users := make([]Follow, len(follows))
+		for i, follow := range follows {
+			users[i] = Follow{UserID: follow.FolloweeID, FollowedAt: follow.CreatedAt}
+		}
+		return users, nil
+	})
+}
+
+// listFollows handles the parts shared by the followers and following
+// endpoints: path and page parsing, the user lookup and the response.
+func (cfg *apiConfig) listFollows(w http.ResponseWriter, r *http.Request, fetch func(uuid.UUID, int, *utils.Cursor) ([]Follow, error)) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	limit, cursor, err := parsePageParams(r, utils.CursorKindTime)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	_, err = cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// fetch one extra row so we know whether there is another page
+	users, err := fetch(userID, limit+1, cursor)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := FollowPage{
+		Users: users,
+	}
+
+	if len(users) > limit {
+		resp.Users = users[:limit]
+		last := resp.Users[limit-1]
+		resp.NextCursor = utils.EncodeCursor(utils.Cursor{
+			Kind:      utils.CursorKindTime,
+			CreatedAt: last.FollowedAt,
+			ID:        last.UserID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) timelineHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requirePrincipal(r).UserID
+
+	limit, cursor, err := parsePageParams(r, utils.CursorKindTime)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	afterCreatedAt, afterID := cursorArgs(cursor)
+
+	// fetch one extra row so we know whether there is another page
+	chirps, err := cfg.db.GetTimeline(r.Context(), database.GetTimelineParams{
+		UserID:         userID,
+		AfterCreatedAt: afterCreatedAt,
+		AfterID:        afterID,
+		PageSize:       int32(limit + 1),
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := ChirpPage{}
+
+	if len(chirps) > limit {
+		chirps = chirps[:limit]
+		last := chirps[len(chirps)-1]
+		resp.NextCursor = utils.EncodeCursor(utils.Cursor{
+			Kind:      utils.CursorKindTime,
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	resp.Chirps, err = cfg.chirpResponses(r.Context(), chirps, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
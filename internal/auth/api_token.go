@@ -0,0 +1,28 @@
+package auth
+
+import "strings"
+
+// API tokens are opaque rather than JWTs so they can be revoked and their
+// scopes changed by updating a row. The prefix tells them apart from
+// access tokens and makes leaked tokens easy to find with secret scanners.
+const (
+	apiTokenPrefix            = "chirpy_"
+	PersonalAccessTokenPrefix = apiTokenPrefix + "pat_"
+	OAuthAccessTokenPrefix    = apiTokenPrefix + "oat_"
+)
+
+// MakeAPIToken returns a new random token with the given prefix. Only its
+// HashToken should be stored.
+func MakeAPIToken(prefix string) (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return prefix + token, nil
+}
+
+// IsAPIToken reports whether a bearer token is an API token rather than an
+// access token.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, apiTokenPrefix)
+}
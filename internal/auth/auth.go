@@ -26,6 +26,25 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 	return match, nil
 }
 
+// dummyHash costs as much to check as the hash of a real password. It is
+// computed at startup, so the first unknown email isn't slower to reject.
+var dummyHash = mustHashPassword("chirpy-dummy-password")
+
+func mustHashPassword(password string) string {
+	hash, err := HashPassword(password)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+// CheckDummyPasswordHash takes as long as CheckPasswordHash on a real hash
+// and never matches. Logins for unknown emails call it so they don't fail
+// faster than wrong passwords for registered ones.
+func CheckDummyPasswordHash(password string) {
+	argon2id.ComparePasswordAndHash(password, dummyHash)
+}
+
 func GetBearerToken(headers http.Header) (string, error) {
 	header := headers.Get("Authorization")
 	if header == "" {
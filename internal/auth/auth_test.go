@@ -2,6 +2,8 @@ package auth
 
 import (
 	"testing"
+
+	"github.com/alexedwards/argon2id"
 )
 
 func TestHashPassword(t *testing.T) {
@@ -62,6 +64,18 @@ func TestCheckPasswordHash_InvalidHash(t *testing.T) {
 	}
 }
 
+func TestDummyHash_SameCost(t *testing.T) {
+	params, _, _, err := argon2id.DecodeHash(dummyHash)
+	if err != nil {
+		t.Fatalf("dummy hash doesn't decode: %v", err)
+	}
+
+	// a cheaper dummy would let response times tell unknown emails apart
+	if *params != *argon2id.DefaultParams {
+		t.Fatalf("dummy hash params = %+v, want %+v", *params, *argon2id.DefaultParams)
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 	headers := make(map[string][]string)
 	headers["Authorization"] = []string{"Bearer someToken12345"}
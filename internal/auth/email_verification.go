@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const emailVerificationIssuer = "chirpy-email-verification"
+
+type emailVerificationClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// MakeEmailVerificationToken signs a token proving that whoever holds it
+// received mail at the given address. The address is part of the token, so
+// it stops working as soon as the user changes their email.
+func MakeEmailVerificationToken(userID uuid.UUID, email, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := emailVerificationClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    emailVerificationIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(tokenSecret))
+}
+
+// ValidateEmailVerificationToken returns the user and the email address a
+// verification token was issued for.
+func ValidateEmailVerificationToken(tokenString, tokenSecret string) (uuid.UUID, string, error) {
+	claims := &emailVerificationClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	}, jwt.WithIssuer(emailVerificationIssuer), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return userID, claims.Email, nil
+}
+
+const emailChangeIssuer = "chirpy-email-change"
+
+type emailChangeClaims struct {
+	Email    string `json:"email"`
+	NewEmail string `json:"new_email"`
+	jwt.RegisteredClaims
+}
+
+// MakeEmailChangeToken signs a token proving that whoever holds it received
+// mail at newEmail. It only applies while the account's address is still
+// email, so it can't undo a later change.
+func MakeEmailChangeToken(userID uuid.UUID, email, newEmail, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := emailChangeClaims{
+		Email:    email,
+		NewEmail: newEmail,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    emailChangeIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(tokenSecret))
+}
+
+// ValidateEmailChangeToken returns the user, the address the change was
+// requested from, and the new address.
+func ValidateEmailChangeToken(tokenString, tokenSecret string) (uuid.UUID, string, string, error) {
+	claims := &emailChangeClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	}, jwt.WithIssuer(emailChangeIssuer), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return uuid.Nil, "", "", err
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, "", "", err
+	}
+	return userID, claims.Email, claims.NewEmail, nil
+}
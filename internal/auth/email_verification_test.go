@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateEmailVerificationToken(t *testing.T) {
+	userID := uuid.New()
+	secret := "badSecret"
+
+	token, err := MakeEmailVerificationToken(userID, "jane@example.com", secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailVerificationToken returned an error: %v", err)
+	}
+
+	returnedUserID, email, err := ValidateEmailVerificationToken(token, secret)
+	if err != nil {
+		t.Fatalf("ValidateEmailVerificationToken returned an error: %v", err)
+	}
+
+	if returnedUserID != userID {
+		t.Fatalf("ValidateEmailVerificationToken returned wrong userID: got %v, want %v", returnedUserID, userID)
+	}
+	if email != "jane@example.com" {
+		t.Fatalf("ValidateEmailVerificationToken returned wrong email: got %q, want %q", email, "jane@example.com")
+	}
+}
+
+func TestValidateEmailVerificationToken_Expired(t *testing.T) {
+	token, err := MakeEmailVerificationToken(uuid.New(), "jane@example.com", "badSecret", -time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailVerificationToken returned an error: %v", err)
+	}
+
+	_, _, err = ValidateEmailVerificationToken(token, "badSecret")
+	if err == nil {
+		t.Fatal("ValidateEmailVerificationToken did not return an error for expired token")
+	}
+}
+
+func TestValidateEmailVerificationToken_WrongSecret(t *testing.T) {
+	token, err := MakeEmailVerificationToken(uuid.New(), "jane@example.com", "badSecret", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailVerificationToken returned an error: %v", err)
+	}
+
+	_, _, err = ValidateEmailVerificationToken(token, "goodSecret")
+	if err == nil {
+		t.Fatal("ValidateEmailVerificationToken did not return an error for wrong secret")
+	}
+}
+
+func TestEmailVerificationToken_NotAnAccessToken(t *testing.T) {
+	secret := "badSecret"
+
+	verificationToken, err := MakeEmailVerificationToken(uuid.New(), "jane@example.com", secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailVerificationToken returned an error: %v", err)
+	}
+	_, err = ValidateJWT(verificationToken, secret)
+	if err == nil {
+		t.Fatal("ValidateJWT accepted an email verification token")
+	}
+
+	accessToken, err := MakeJWT(uuid.New(), secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned an error: %v", err)
+	}
+	_, _, err = ValidateEmailVerificationToken(accessToken, secret)
+	if err == nil {
+		t.Fatal("ValidateEmailVerificationToken accepted an access token")
+	}
+}
+
+func TestValidateEmailChangeToken(t *testing.T) {
+	userID := uuid.New()
+	secret := "badSecret"
+
+	token, err := MakeEmailChangeToken(userID, "jane@example.com", "jane@example.org", secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailChangeToken returned an error: %v", err)
+	}
+
+	returnedUserID, email, newEmail, err := ValidateEmailChangeToken(token, secret)
+	if err != nil {
+		t.Fatalf("ValidateEmailChangeToken returned an error: %v", err)
+	}
+
+	if returnedUserID != userID {
+		t.Fatalf("ValidateEmailChangeToken returned wrong userID: got %v, want %v", returnedUserID, userID)
+	}
+	if email != "jane@example.com" || newEmail != "jane@example.org" {
+		t.Fatalf("ValidateEmailChangeToken returned wrong emails: got %q, %q", email, newEmail)
+	}
+}
+
+func TestEmailChangeToken_NotAVerificationToken(t *testing.T) {
+	secret := "badSecret"
+
+	changeToken, err := MakeEmailChangeToken(uuid.New(), "jane@example.com", "jane@example.org", secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailChangeToken returned an error: %v", err)
+	}
+	_, _, err = ValidateEmailVerificationToken(changeToken, secret)
+	if err == nil {
+		t.Fatal("ValidateEmailVerificationToken accepted an email change token")
+	}
+
+	verificationToken, err := MakeEmailVerificationToken(uuid.New(), "jane@example.com", secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailVerificationToken returned an error: %v", err)
+	}
+	_, _, _, err = ValidateEmailChangeToken(verificationToken, secret)
+	if err == nil {
+		t.Fatal("ValidateEmailChangeToken accepted an email verification token")
+	}
+}
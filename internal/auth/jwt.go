@@ -7,41 +7,119 @@ import (
 	"github.com/google/uuid"
 )
 
+// accessTokenIssuer is checked on every access token so that other JWTs
+// signed with the same secret can't be used to authenticate.
+const accessTokenIssuer = "chirpy"
 
+// mfaTokenIssuer marks the short-lived token handed out between the
+// password and the second factor of a login.
+const mfaTokenIssuer = "chirpy-mfa"
 
+
+
+// MakeJWT signs an HS256 access token with a shared secret. It is
+// NewHMACKeyring(tokenSecret).MakeJWT.
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Issuer: "chirpy",
-		IssuedAt: jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject: userID.String(),
+	return NewHMACKeyring(tokenSecret).MakeJWT(userID, expiresIn)
+}
+
+
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	return NewHMACKeyring(tokenSecret).ValidateJWT(tokenString)
+}
+
+// MakeSessionJWT is MakeJWT with the session ID recorded in the "sid"
+// claim.
+func MakeSessionJWT(userID, sessionID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return NewHMACKeyring(tokenSecret).MakeSessionJWT(userID, sessionID, expiresIn)
+}
+
+func ValidateSessionJWT(tokenString, tokenSecret string) (uuid.UUID, uuid.UUID, error) {
+	return NewHMACKeyring(tokenSecret).ValidateSessionJWT(tokenString)
+}
+
+// accessTokenClaims adds the session an access token was issued for, so
+// requests can be tied back to the refresh token family behind them.
+type accessTokenClaims struct {
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (k *Keyring) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return k.MakeSessionJWT(userID, uuid.Nil, expiresIn)
+}
+
+func (k *Keyring) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	userID, _, err := k.ValidateSessionJWT(tokenString)
+	return userID, err
+}
+
+// MakeSessionJWT signs an access token that records the session it was
+// issued for. A nil session leaves the "sid" claim out.
+func (k *Keyring) MakeSessionJWT(userID, sessionID uuid.UUID, expiresIn time.Duration) (string, error) {
+	claims := accessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    accessTokenIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+	}
+	if sessionID != uuid.Nil {
+		claims.SessionID = sessionID.String()
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(tokenSecret))
+	return k.sign(claims)
+}
+
+// ValidateSessionJWT validates an access token and returns the user and
+// the session it belongs to. The session is uuid.Nil for tokens issued
+// without one.
+func (k *Keyring) ValidateSessionJWT(tokenString string) (uuid.UUID, uuid.UUID, error) {
+	claims := &accessTokenClaims{}
+	err := k.parse(tokenString, claims, accessTokenIssuer)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return "", err
+		return uuid.Nil, uuid.Nil, err
 	}
 
-	return signedToken, nil
+	if claims.SessionID == "" {
+		return userID, uuid.Nil, nil
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return userID, sessionID, nil
 }
 
+// MakeMFAToken issues a token that proves the password step of a login
+// succeeded. It is only accepted by ValidateMFAToken, never as an access
+// token.
+func MakeMFAToken(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    mfaTokenIssuer,
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		Subject:   userID.String(),
+	}
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(tokenSecret))
+}
+
+func ValidateMFAToken(tokenString, tokenSecret string) (uuid.UUID, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
-	})
+	}, jwt.WithIssuer(mfaTokenIssuer), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil {
 		return uuid.Nil, err
 	}
 
-	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok && token.Valid {
-		userID, err := uuid.Parse(claims.Subject)
-		if err != nil {
-			return uuid.Nil, err
-		}
-		return userID, nil
-	} else {
-		return uuid.Nil, jwt.ErrTokenInvalidClaims
-	}
-}
\ No newline at end of file
+	return uuid.Parse(claims.Subject)
+}
@@ -71,4 +71,75 @@ func TestValidateJWT_WrongSecret(t *testing.T) {
 	if err == nil {
 		t.Fatal("ValidateJWT did not return an error for wrong secret")
 	}
-}
\ No newline at end of file
+}
+
+func TestValidateMFAToken(t *testing.T) {
+	userID := uuid.New()
+	secret := "badSecret"
+
+	token, err := MakeMFAToken(userID, secret, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeMFAToken returned an error: %v", err)
+	}
+
+	returnedUserID, err := ValidateMFAToken(token, secret)
+	if err != nil {
+		t.Fatalf("ValidateMFAToken returned an error: %v", err)
+	}
+	if returnedUserID != userID {
+		t.Fatalf("ValidateMFAToken returned wrong userID: got %v, want %v", returnedUserID, userID)
+	}
+
+	// an MFA token must not work as an access token, or the second
+	// factor could be skipped
+	_, err = ValidateJWT(token, secret)
+	if err == nil {
+		t.Fatal("ValidateJWT accepted an MFA token")
+	}
+}
+
+func TestValidateSessionJWT(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+	secret := "badSecret"
+
+	token, err := MakeSessionJWT(userID, sessionID, secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeSessionJWT returned an error: %v", err)
+	}
+
+	returnedUserID, returnedSessionID, err := ValidateSessionJWT(token, secret)
+	if err != nil {
+		t.Fatalf("ValidateSessionJWT returned an error: %v", err)
+	}
+	if returnedUserID != userID {
+		t.Fatalf("ValidateSessionJWT returned wrong userID: got %v, want %v", returnedUserID, userID)
+	}
+	if returnedSessionID != sessionID {
+		t.Fatalf("ValidateSessionJWT returned wrong sessionID: got %v, want %v", returnedSessionID, sessionID)
+	}
+
+	// session tokens are still plain access tokens
+	returnedUserID, err = ValidateJWT(token, secret)
+	if err != nil || returnedUserID != userID {
+		t.Fatalf("ValidateJWT did not accept a session token: %v", err)
+	}
+}
+
+func TestValidateSessionJWT_WithoutSession(t *testing.T) {
+	userID := uuid.New()
+	secret := "badSecret"
+
+	token, err := MakeJWT(userID, secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned an error: %v", err)
+	}
+
+	_, sessionID, err := ValidateSessionJWT(token, secret)
+	if err != nil {
+		t.Fatalf("ValidateSessionJWT returned an error: %v", err)
+	}
+	if sessionID != uuid.Nil {
+		t.Fatalf("ValidateSessionJWT returned sessionID %v for a token without one", sessionID)
+	}
+}
@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSAKeyBits = 2048
+
+// Key is one asymmetric JWT key. Verification-only keys have no private
+// half. ID is the RFC 7638 thumbprint of the public key and is sent as the
+// "kid" header of every token the key signs.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+	jwk     JWK
+}
+
+// JWK is the public half of a Key as published in a JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Keyring signs access tokens with one key and verifies them with any of
+// its keys, which lets a new signing key be rolled out while tokens signed
+// by the previous one are still in circulation. A keyring can also accept
+// HS256 tokens signed with a shared secret, which is how tokens were signed
+// before keys were configured.
+type Keyring struct {
+	signing    *Key
+	keys       map[string]*Key
+	hmacSecret []byte
+}
+
+// NewKeyring signs with the signing key and verifies with it and every
+// verification key.
+func NewKeyring(signing *Key, verification ...*Key) *Keyring {
+	k := &Keyring{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+	for _, key := range verification {
+		k.keys[key.ID] = key
+	}
+	return k
+}
+
+// NewHMACKeyring signs and verifies HS256 tokens with a shared secret and
+// publishes no keys.
+func NewHMACKeyring(secret string) *Keyring {
+	return &Keyring{
+		keys:       map[string]*Key{},
+		hmacSecret: []byte(secret),
+	}
+}
+
+// AllowHMAC makes the keyring also accept HS256 tokens without a key ID
+// that were signed with secret.
+func (k *Keyring) AllowHMAC(secret string) {
+	k.hmacSecret = []byte(secret)
+}
+
+// LoadKeyring reads a PEM private key to sign with and any number of PEM
+// keys, public or private, that are only used to verify.
+func LoadKeyring(signingKeyFile string, verificationKeyFiles []string) (*Keyring, error) {
+	data, err := os.ReadFile(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	signing, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", signingKeyFile, err)
+	}
+
+	verification := make([]*Key, 0, len(verificationKeyFiles))
+	for _, file := range verificationKeyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		verification = append(verification, key)
+	}
+
+	return NewKeyring(signing, verification...), nil
+}
+
+// ParsePrivateKeyPEM reads an RSA (PKCS #1 or #8) or Ed25519 (PKCS #8)
+// private key.
+func ParsePrivateKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return NewKey(signer)
+}
+
+// ParsePublicKeyPEM reads a PKIX public key. A private key is accepted too
+// and only its public half is kept.
+func ParsePublicKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if block.Type != "PUBLIC KEY" {
+		key, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return newKey(nil, key.public)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(nil, parsed)
+}
+
+// NewKey wraps an *rsa.PrivateKey or ed25519.PrivateKey for signing.
+func NewKey(private crypto.Signer) (*Key, error) {
+	return newKey(private, private.Public())
+}
+
+func newKey(private crypto.Signer, public crypto.PublicKey) (*Key, error) {
+	key := &Key{
+		private: private,
+		public:  public,
+	}
+
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.jwk = JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.jwk = JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+
+	key.ID = jwkThumbprint(key.jwk)
+	key.jwk.Kid = key.ID
+	key.jwk.Use = "sig"
+	key.jwk.Alg = key.Method.Alg()
+	return key, nil
+}
+
+// jwkThumbprint implements RFC 7638: the SHA-256 of the required members
+// of the JWK in lexicographic order, without whitespace.
+func jwkThumbprint(jwk JWK) string {
+	var canonical string
+	switch jwk.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Crv, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// JWKS returns the public keys other services need to verify our tokens.
+// HMAC secrets are never published.
+func (k *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if k.signing != nil {
+		set.Keys = append(set.Keys, k.signing.jwk)
+	}
+	for id, key := range k.keys {
+		if k.signing != nil && id == k.signing.ID {
+			continue
+		}
+		set.Keys = append(set.Keys, key.jwk)
+	}
+	return set
+}
+
+func (k *Keyring) sign(claims jwt.Claims) (string, error) {
+	if k.signing == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(k.hmacSecret)
+	}
+
+	token := jwt.NewWithClaims(k.signing.Method, claims)
+	token.Header["kid"] = k.signing.ID
+	return token.SignedString(k.signing.private)
+}
+
+// keyfunc picks the verification key for a token. The algorithm has to be
+// the one that belongs to the key, otherwise a token could be "signed"
+// with HS256 using a published public key as the secret.
+func (k *Keyring) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if k.hmacSecret != nil && token.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			return k.hmacSecret, nil
+		}
+		return nil, errors.New("token has no key ID")
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("signing method does not match key")
+	}
+	return key.public, nil
+}
+
+func (k *Keyring) parse(tokenString string, claims jwt.Claims, issuer string) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, k.keyfunc,
+		jwt.WithIssuer(issuer),
+		jwt.WithValidMethods([]string{
+			jwt.SigningMethodHS256.Alg(),
+			jwt.SigningMethodRS256.Alg(),
+			jwt.SigningMethodEdDSA.Alg(),
+		}),
+	)
+	return err
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func newTestRSAKey(t *testing.T) *Key {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey returned an error: %v", err)
+	}
+	key, err := NewKey(private)
+	if err != nil {
+		t.Fatalf("NewKey returned an error: %v", err)
+	}
+	return key
+}
+
+func newTestEd25519Key(t *testing.T) *Key {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey returned an error: %v", err)
+	}
+	key, err := NewKey(private)
+	if err != nil {
+		t.Fatalf("NewKey returned an error: %v", err)
+	}
+	return key
+}
+
+func TestKeyring_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		key  *Key
+		alg  string
+	}{
+		{"RSA", newTestRSAKey(t), "RS256"},
+		{"Ed25519", newTestEd25519Key(t), "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring := NewKeyring(tt.key)
+			userID := uuid.New()
+			sessionID := uuid.New()
+
+			token, err := keyring.MakeSessionJWT(userID, sessionID, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeSessionJWT returned an error: %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified returned an error: %v", err)
+			}
+			if parsed.Header["alg"] != tt.alg || parsed.Header["kid"] != tt.key.ID {
+				t.Errorf("header = %v, want alg %s and kid %s", parsed.Header, tt.alg, tt.key.ID)
+			}
+
+			gotUser, gotSession, err := keyring.ValidateSessionJWT(token)
+			if err != nil {
+				t.Fatalf("ValidateSessionJWT returned an error: %v", err)
+			}
+			if gotUser != userID || gotSession != sessionID {
+				t.Errorf("got (%v, %v), want (%v, %v)", gotUser, gotSession, userID, sessionID)
+			}
+		})
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	oldKey := newTestRSAKey(t)
+	newKey := newTestEd25519Key(t)
+	userID := uuid.New()
+
+	token, err := NewKeyring(oldKey).MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned an error: %v", err)
+	}
+
+	rotated := NewKeyring(newKey, oldKey)
+	got, err := rotated.ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT with the old key still configured returned an error: %v", err)
+	}
+	if got != userID {
+		t.Errorf("ValidateJWT returned %v, want %v", got, userID)
+	}
+
+	if len(rotated.JWKS().Keys) != 2 {
+		t.Errorf("JWKS has %d keys, want 2", len(rotated.JWKS().Keys))
+	}
+	if rotated.JWKS().Keys[0].Kid != newKey.ID {
+		t.Errorf("JWKS should list the signing key first")
+	}
+
+	_, err = NewKeyring(newKey).ValidateJWT(token)
+	if err == nil {
+		t.Error("ValidateJWT accepted a token signed by a retired key")
+	}
+}
+
+func TestKeyring_HMACFallback(t *testing.T) {
+	userID := uuid.New()
+	token, err := MakeJWT(userID, "secret", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned an error: %v", err)
+	}
+
+	keyring := NewKeyring(newTestRSAKey(t))
+	_, err = keyring.ValidateJWT(token)
+	if err == nil {
+		t.Fatal("ValidateJWT accepted an HS256 token without the fallback")
+	}
+
+	keyring.AllowHMAC("secret")
+	got, err := keyring.ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT returned an error with the fallback: %v", err)
+	}
+	if got != userID {
+		t.Errorf("ValidateJWT returned %v, want %v", got, userID)
+	}
+}
+
+func TestKeyring_AlgorithmConfusion(t *testing.T) {
+	key := newTestRSAKey(t)
+	keyring := NewKeyring(key)
+
+	// HS256 "signed" with the published public key as the secret
+	publicDER, err := x509.MarshalPKIXPublicKey(key.public)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey returned an error: %v", err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    accessTokenIssuer,
+		Subject:   uuid.New().String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	token.Header["kid"] = key.ID
+	forged, err := token.SignedString(publicPEM)
+	if err != nil {
+		t.Fatalf("SignedString returned an error: %v", err)
+	}
+
+	_, err = keyring.ValidateJWT(forged)
+	if err == nil {
+		t.Fatal("ValidateJWT accepted an HS256 token for an RSA key")
+	}
+}
+
+func TestKeyring_UnknownKeyID(t *testing.T) {
+	token, err := NewKeyring(newTestEd25519Key(t)).MakeJWT(uuid.New(), time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned an error: %v", err)
+	}
+
+	_, err = NewKeyring(newTestEd25519Key(t)).ValidateJWT(token)
+	if err == nil {
+		t.Fatal("ValidateJWT accepted a token with an unknown key ID")
+	}
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey returned an error: %v", err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey returned an error: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey returned an error: %v", err)
+	}
+
+	signing, err := ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM returned an error: %v", err)
+	}
+	verification, err := ParsePublicKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if err != nil {
+		t.Fatalf("ParsePublicKeyPEM returned an error: %v", err)
+	}
+	if signing.ID != verification.ID {
+		t.Errorf("key IDs differ: %s and %s", signing.ID, verification.ID)
+	}
+
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey returned an error: %v", err)
+	}
+	_, err = ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(small)}))
+	if err == nil {
+		t.Error("ParsePrivateKeyPEM accepted a 1024-bit RSA key")
+	}
+}
+
+// The example key from RFC 7638, section 3.1.
+func TestJWKThumbprint(t *testing.T) {
+	jwk := JWK{
+		Kty: "RSA",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+	}
+
+	got := jwkThumbprint(jwk)
+	want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+	if got != want {
+		t.Errorf("jwkThumbprint = %s, want %s", got, want)
+	}
+}
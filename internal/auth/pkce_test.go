@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// example from RFC 7636, appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !ValidCodeChallenge(challenge) {
+		t.Fatal("ValidCodeChallenge rejected the RFC 7636 challenge")
+	}
+	if !VerifyPKCE(verifier, challenge) {
+		t.Fatal("VerifyPKCE rejected the RFC 7636 verifier")
+	}
+	if VerifyPKCE(strings.Replace(verifier, "d", "e", 1), challenge) {
+		t.Error("VerifyPKCE accepted the wrong verifier")
+	}
+	// "plain" would send the verifier itself as the challenge
+	if VerifyPKCE(verifier, verifier) {
+		t.Error("VerifyPKCE accepted a plain challenge")
+	}
+	if VerifyPKCE("too-short", challenge) {
+		t.Error("VerifyPKCE accepted a verifier shorter than 43 characters")
+	}
+}
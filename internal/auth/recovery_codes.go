@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+)
+
+// recoveryCodeEncoding avoids padding and case so codes are easy to type.
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCodes returns n random one-time codes such as
+// "k3f9-x2ma-q7pd-w4hn". Each carries 80 bits of entropy, enough for them
+// to be stored with HashToken.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		raw := make([]byte, 10)
+		_, err := rand.Read(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded := recoveryCodeEncoding.EncodeToString(raw)
+		codes[i] = encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode strips the formatting a user may or may not type
+// so the code can be hashed and compared.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,34 @@
+package auth
+
+import "testing"
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes returned an error: %v", err)
+	}
+
+	if len(codes) != 10 {
+		t.Fatalf("Expected 10 codes, got %d", len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 19 {
+			t.Errorf("Expected code length of 19, got %d (%q)", len(code), code)
+		}
+		if seen[code] {
+			t.Errorf("Duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	want := "k3f9x2maq7pdw4hn"
+	for _, input := range []string{"k3f9-x2ma-q7pd-w4hn", "K3F9-X2MA-Q7PD-W4HN", "k3f9 x2ma q7pd w4hn", want} {
+		if got := NormalizeRecoveryCode(input); got != want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
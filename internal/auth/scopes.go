@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scopes limit what an API token can do. Access tokens from a password
+// login are not scoped and can do everything.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileWrite = "profile:write"
+)
+
+var knownScopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileWrite}
+
+// AllScopes returns every scope, which is what an access token from a
+// login is allowed to do.
+func AllScopes() []string {
+	return slices.Clone(knownScopes)
+}
+
+var ErrInsufficientScope = errors.New("token does not have the required scope")
+
+// ParseScopes reads a space-separated OAuth scope parameter.
+func ParseScopes(scope string) ([]string, error) {
+	return ValidateScopes(strings.Fields(scope))
+}
+
+// ValidateScopes rejects unknown scopes and returns the rest sorted and
+// without duplicates, so the same grant is always stored the same way.
+func ValidateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+
+	valid := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(knownScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		valid = append(valid, scope)
+	}
+
+	slices.Sort(valid)
+	return slices.Compact(valid), nil
+}
+
+// CheckScope returns ErrInsufficientScope unless scope is one of granted.
+func CheckScope(granted []string, scope string) error {
+	if !slices.Contains(granted, scope) {
+		return ErrInsufficientScope
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	got, err := ParseScopes("chirps:write  chirps:read chirps:write")
+	if err != nil {
+		t.Fatalf("ParseScopes returned an error: %v", err)
+	}
+	want := []string{ScopeChirpsRead, ScopeChirpsWrite}
+	if !slices.Equal(got, want) {
+		t.Fatalf("ParseScopes returned %v, want %v", got, want)
+	}
+
+	for _, scope := range []string{"", "chirps:read admin", "CHIRPS:READ"} {
+		if _, err := ParseScopes(scope); err == nil {
+			t.Errorf("ParseScopes(%q) did not return an error", scope)
+		}
+	}
+}
+
+func TestCheckScope(t *testing.T) {
+	granted := []string{ScopeChirpsRead}
+	if err := CheckScope(granted, ScopeChirpsRead); err != nil {
+		t.Errorf("CheckScope returned an error for a granted scope: %v", err)
+	}
+	if err := CheckScope(granted, ScopeChirpsWrite); !errors.Is(err, ErrInsufficientScope) {
+		t.Errorf("CheckScope returned %v, want ErrInsufficientScope", err)
+	}
+}
@@ -0,0 +1,15 @@
+package auth
+
+import "testing"
+
+func TestHashToken(t *testing.T) {
+	// sha256("abc") from FIPS 180-2
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != want {
+		t.Fatalf("HashToken returned %q, want %q", got, want)
+	}
+
+	if HashToken("token-a") == HashToken("token-b") {
+		t.Fatal("HashToken returned the same hash for different tokens")
+	}
+}
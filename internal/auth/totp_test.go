@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 4226 appendix D
+func TestHOTP(t *testing.T) {
+	key := []byte("12345678901234567890")
+	expected := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	for counter, want := range expected {
+		got := hotp(key, uint64(counter), 6)
+		if got != want {
+			t.Errorf("hotp(counter=%d) = %s, want %s", counter, got, want)
+		}
+	}
+}
+
+// RFC 6238 appendix B, SHA1 rows
+func TestTOTPVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tc := range tests {
+		got := hotp(key, totpStep(time.Unix(tc.unix, 0)), 8)
+		if got != tc.want {
+			t.Errorf("TOTP at %d = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret returned an error: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("TOTPCode returned an error: %v", err)
+	}
+
+	step, ok := ValidateTOTP(secret, code, now)
+	if !ok {
+		t.Fatal("ValidateTOTP rejected the current code")
+	}
+	if step != int64(totpStep(now)) {
+		t.Fatalf("ValidateTOTP returned step %d, want %d", step, totpStep(now))
+	}
+
+	// one step of drift either way is accepted
+	if _, ok := ValidateTOTP(secret, code, now.Add(totpPeriod*time.Second)); !ok {
+		t.Fatal("ValidateTOTP rejected a code from the previous step")
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(-totpPeriod*time.Second)); !ok {
+		t.Fatal("ValidateTOTP rejected a code from the next step")
+	}
+
+	if _, ok := ValidateTOTP(secret, code, now.Add(3*totpPeriod*time.Second)); ok {
+		t.Fatal("ValidateTOTP accepted a code from three steps ago")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Fatal("ValidateTOTP accepted a short code")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("JBSWY3DPEHPK3PXP", "Chirpy", "jane@example.com")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:jane@example.com?") {
+		t.Fatalf("TOTPURI returned unexpected label: %s", uri)
+	}
+	for _, want := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=Chirpy", "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("TOTPURI %q does not contain %q", uri, want)
+		}
+	}
+}
@@ -7,29 +7,62 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const cancelScheduledChirp = `-- name: CancelScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND publish_at > NOW()
+`
+
+type CancelScheduledChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) CancelScheduledChirp(ctx context.Context, arg CancelScheduledChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, cancelScheduledChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_id, rechirp_of_id, publish_at)
 VALUES (
     gen_random_uuid(),
+    COALESCE($1::timestamptz, NOW()),
     NOW(),
-    NOW(),
-    $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $1
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	PublishAt   sql.NullTime
+	Body        string
+	UserID      uuid.UUID
+	ParentID    uuid.NullUUID
+	RechirpOfID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.PublishAt,
+		arg.Body,
+		arg.UserID,
+		arg.ParentID,
+		arg.RechirpOfID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,30 +70,159 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Search,
+		&i.ParentID,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.DeletedAt,
+		&i.PublishAt,
 	)
 	return i, err
 }
 
-const deleteChirpById = `-- name: DeleteChirpById :exec
-DELETE FROM chirps WHERE id = $1 AND user_id = $2
+const getChirpById = `-- name: GetChirpById :one
+SELECT id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at FROM chirps
+WHERE id = $1
+    AND deleted_at IS NULL
+    AND (publish_at IS NULL OR publish_at <= NOW() OR user_id = $2)
 `
 
-type DeleteChirpByIdParams struct {
-	ID     uuid.UUID
-	UserID uuid.UUID
+type GetChirpByIdParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
 }
 
-func (q *Queries) DeleteChirpById(ctx context.Context, arg DeleteChirpByIdParams) error {
-	_, err := q.db.ExecContext(ctx, deleteChirpById, arg.ID, arg.UserID)
-	return err
+func (q *Queries) GetChirpById(ctx context.Context, arg GetChirpByIdParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpById, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Search,
+		&i.ParentID,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.DeletedAt,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const getChirpByIdForUpdate = `-- name: GetChirpByIdForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at FROM chirps
+WHERE id = $1
+    AND deleted_at IS NULL
+    AND (publish_at IS NULL OR publish_at <= NOW() OR user_id = $2)
+FOR UPDATE
+`
+
+type GetChirpByIdForUpdateParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetChirpByIdForUpdate(ctx context.Context, arg GetChirpByIdForUpdateParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIdForUpdate, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Search,
+		&i.ParentID,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.DeletedAt,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const getChirpThread = `-- name: GetChirpThread :many
+WITH RECURSIVE thread (id, depth) AS (
+    SELECT chirps.id, 0
+    FROM chirps
+    WHERE chirps.id = $1
+        AND (chirps.publish_at IS NULL OR chirps.publish_at <= NOW() OR chirps.user_id = $2)
+    UNION ALL
+    SELECT replies.id, thread.depth + 1
+    FROM chirps replies
+    INNER JOIN thread ON replies.parent_id = thread.id
+    WHERE thread.depth < $3::int
+        AND (replies.publish_at IS NULL OR replies.publish_at <= NOW() OR replies.user_id = $2)
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search, chirps.parent_id, chirps.like_count, chirps.rechirp_of_id, chirps.deleted_at, chirps.publish_at, thread.depth::int AS depth
+FROM thread
+INNER JOIN chirps ON chirps.id = thread.id
+ORDER BY thread.depth ASC, chirps.created_at ASC, chirps.id ASC
+`
+
+type GetChirpThreadParams struct {
+	RootID   uuid.UUID
+	ViewerID uuid.NullUUID
+	MaxDepth int32
+}
+
+type GetChirpThreadRow struct {
+	Chirp Chirp
+	Depth int32
+}
+
+func (q *Queries) GetChirpThread(ctx context.Context, arg GetChirpThreadParams) ([]GetChirpThreadRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpThread, arg.RootID, arg.ViewerID, arg.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpThreadRow
+	for rows.Next() {
+		var i GetChirpThreadRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.Search,
+			&i.Chirp.ParentID,
+			&i.Chirp.LikeCount,
+			&i.Chirp.RechirpOfID,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.PublishAt,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC
+const getChirpsByIds = `-- name: GetChirpsByIds :many
+SELECT id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at FROM chirps
+WHERE id = ANY($1::uuid[])
+    AND deleted_at IS NULL
+    AND (publish_at IS NULL OR publish_at <= NOW() OR user_id = $2)
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+type GetChirpsByIdsParams struct {
+	Ids      []uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetChirpsByIds(ctx context.Context, arg GetChirpsByIdsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIds, pq.Array(arg.Ids), arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -74,6 +236,12 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Search,
+			&i.ParentID,
+			&i.LikeCount,
+			&i.RechirpOfID,
+			&i.DeletedAt,
+			&i.PublishAt,
 		); err != nil {
 			return nil, err
 		}
@@ -88,12 +256,12 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpById = `-- name: GetChirpById :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+const getDeletedChirpById = `-- name: GetDeletedChirpById :one
+SELECT id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at FROM chirps WHERE id = $1 AND deleted_at IS NOT NULL
 `
 
-func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpById, id)
+func (q *Queries) GetDeletedChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedChirpById, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -101,18 +269,208 @@ func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Search,
+		&i.ParentID,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.DeletedAt,
+		&i.PublishAt,
 	)
 	return i, err
 }
 
-const getChirpsByAuthorId = `-- name: GetChirpsByAuthorId :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at FROM chirps
+WHERE deleted_at IS NULL
+    AND (publish_at IS NULL OR publish_at <= NOW() OR user_id = $1)
+    AND ($2::uuid IS NULL OR user_id = $2)
+    AND (
+        $3::timestamptz IS NULL
+        OR (created_at, id) > ($3, $4::uuid)
+    )
+ORDER BY created_at ASC, id ASC
+LIMIT $5
+`
+
+type ListChirpsAscParams struct {
+	ViewerID       uuid.NullUUID
+	AuthorID       uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	PageSize       int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.ViewerID,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Search,
+			&i.ParentID,
+			&i.LikeCount,
+			&i.RechirpOfID,
+			&i.DeletedAt,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at FROM chirps
+WHERE deleted_at IS NULL
+    AND (publish_at IS NULL OR publish_at <= NOW() OR user_id = $1)
+    AND ($2::uuid IS NULL OR user_id = $2)
+    AND (
+        $3::timestamptz IS NULL
+        OR (created_at, id) < ($3, $4::uuid)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type ListChirpsDescParams struct {
+	ViewerID       uuid.NullUUID
+	AuthorID       uuid.NullUUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	PageSize       int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.ViewerID,
+		arg.AuthorID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Search,
+			&i.ParentID,
+			&i.LikeCount,
+			&i.RechirpOfID,
+			&i.DeletedAt,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsForExport = `-- name: ListChirpsForExport :many
+SELECT id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at FROM chirps
+WHERE user_id = $1
+    AND (
+        $2::timestamptz IS NULL
+        OR (created_at, id) > ($2, $3::uuid)
+    )
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type ListChirpsForExportParams struct {
+	UserID         uuid.UUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	PageSize       int32
+}
+
+func (q *Queries) ListChirpsForExport(ctx context.Context, arg ListChirpsForExportParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsForExport,
+		arg.UserID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Search,
+			&i.ParentID,
+			&i.LikeCount,
+			&i.RechirpOfID,
+			&i.DeletedAt,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledChirps = `-- name: ListScheduledChirps :many
+SELECT id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at FROM chirps
 WHERE user_id = $1
-ORDER BY created_at ASC
+    AND deleted_at IS NULL
+    AND publish_at > NOW()
+ORDER BY publish_at ASC, id ASC
 `
 
-func (q *Queries) GetChirpsByAuthorId(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorId, userID)
+func (q *Queries) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledChirps, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -126,6 +484,131 @@ func (q *Queries) GetChirpsByAuthorId(ctx context.Context, userID uuid.UUID) ([]
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Search,
+			&i.ParentID,
+			&i.LikeCount,
+			&i.RechirpOfID,
+			&i.DeletedAt,
+			&i.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < $1::timestamptz
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirpById = `-- name: RestoreChirpById :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = $1
+    AND user_id = $2
+    AND deleted_at > $3::timestamptz
+RETURNING id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at
+`
+
+type RestoreChirpByIdParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) RestoreChirpById(ctx context.Context, arg RestoreChirpByIdParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirpById, arg.ID, arg.UserID, arg.DeletedAfter)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Search,
+		&i.ParentID,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.DeletedAt,
+		&i.PublishAt,
+	)
+	return i, err
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search, chirps.parent_id, chirps.like_count, chirps.rechirp_of_id, chirps.deleted_at, chirps.publish_at, ts_rank(chirps.search, websearch_to_tsquery('english', $1)) AS rank
+FROM chirps
+WHERE chirps.search @@ websearch_to_tsquery('english', $1)
+    AND chirps.deleted_at IS NULL
+    AND (chirps.publish_at IS NULL OR chirps.publish_at <= NOW() OR chirps.user_id = $2)
+    AND ($3::uuid IS NULL OR chirps.user_id = $3)
+    AND (
+        $4::real IS NULL
+        OR (ts_rank(chirps.search, websearch_to_tsquery('english', $1)), chirps.id) < ($4, $5::uuid)
+    )
+ORDER BY rank DESC, chirps.id DESC
+LIMIT $6
+`
+
+type SearchChirpsParams struct {
+	Query     string
+	ViewerID  uuid.NullUUID
+	AuthorID  uuid.NullUUID
+	AfterRank sql.NullFloat64
+	AfterID   uuid.NullUUID
+	PageSize  int32
+}
+
+type SearchChirpsRow struct {
+	Chirp Chirp
+	Rank  float32
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.ViewerID,
+		arg.AuthorID,
+		arg.AfterRank,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.Search,
+			&i.Chirp.ParentID,
+			&i.Chirp.LikeCount,
+			&i.Chirp.RechirpOfID,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.PublishAt,
+			&i.Rank,
 		); err != nil {
 			return nil, err
 		}
@@ -139,3 +622,51 @@ func (q *Queries) GetChirpsByAuthorId(ctx context.Context, userID uuid.UUID) ([]
 	}
 	return items, nil
 }
+
+const softDeleteChirpById = `-- name: SoftDeleteChirpById :exec
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+`
+
+type SoftDeleteChirpByIdParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) SoftDeleteChirpById(ctx context.Context, arg SoftDeleteChirpByIdParams) error {
+	_, err := q.db.ExecContext(ctx, softDeleteChirpById, arg.ID, arg.UserID)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, search, parent_id, like_count, rechirp_of_id, deleted_at, publish_at
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Search,
+		&i.ParentID,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.DeletedAt,
+		&i.PublishAt,
+	)
+	return i, err
+}
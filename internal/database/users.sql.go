@@ -7,29 +7,79 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+    AND deletion_requested_at IS NOT NULL
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
+const changeUserEmail = `-- name: ChangeUserEmail :execrows
+UPDATE users
+SET email = $1,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $2
+    AND email = $3
+`
+
+type ChangeUserEmailParams struct {
+	NewEmail string
+	ID       uuid.UUID
+	Email    string
+}
+
+func (q *Queries) ChangeUserEmail(ctx context.Context, arg ChangeUserEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, changeUserEmail, arg.NewEmail, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username, display_name, bio)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Username       sql.NullString
+	DisplayName    string
+	Bio            string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Username,
+		arg.DisplayName,
+		arg.Bio,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -38,6 +88,13 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.DeletionRequestedAt,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
@@ -52,7 +109,7 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -65,27 +122,345 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.DeletionRequestedAt,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const getUserById = `-- name: GetUserById :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserById, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.DeletionRequestedAt,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const getUserProfileByUsername = `-- name: GetUserProfileByUsername :one
+SELECT
+    users.id,
+    users.created_at,
+    users.username,
+    users.display_name,
+    users.bio,
+    (
+        SELECT COUNT(*) FROM chirps
+        WHERE chirps.user_id = users.id
+            AND chirps.deleted_at IS NULL
+            AND (chirps.publish_at IS NULL OR chirps.publish_at <= NOW())
+    ) AS chirp_count
+FROM users
+WHERE LOWER(users.username) = LOWER($1::text)
+`
+
+type GetUserProfileByUsernameRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	Username    sql.NullString
+	DisplayName string
+	Bio         string
+	ChirpCount  int64
+}
+
+func (q *Queries) GetUserProfileByUsername(ctx context.Context, username string) (GetUserProfileByUsernameRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserProfileByUsername, username)
+	var i GetUserProfileByUsernameRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.ChirpCount,
+	)
+	return i, err
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at FROM users
+WHERE (
+        $1::text IS NULL
+        OR strpos(LOWER(email), LOWER($1)) > 0
+        OR strpos(LOWER(username), LOWER($1)) > 0
+    )
+    AND ($2::text IS NULL OR role = $2)
+    AND (
+        $3::timestamptz IS NULL
+        OR (created_at, id) < ($3, $4::uuid)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type ListUsersParams struct {
+	Query          sql.NullString
+	Role           sql.NullString
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	PageSize       int32
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers,
+		arg.Query,
+		arg.Role,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Username,
+			&i.DisplayName,
+			&i.Bio,
+			&i.DeletionRequestedAt,
+			&i.EmailVerifiedAt,
+			&i.Role,
+			&i.SuspendedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deletion_requested_at IS NOT NULL
+    AND deletion_requested_at < $1::timestamptz
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, requestedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, requestedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const requestUserDeletion = `-- name: RequestUserDeletion :one
+UPDATE users
+SET deletion_requested_at = COALESCE(deletion_requested_at, NOW()),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at
+`
+
+func (q *Queries) RequestUserDeletion(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, requestUserDeletion, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.DeletionRequestedAt,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
 
-const updateUserEmailPassword = `-- name: UpdateUserEmailPassword :one
-UPDATE users 
-SET email = $2,
-    hashed_password = $3,
+const setUserChirpyRed = `-- name: SetUserChirpyRed :one
+UPDATE users
+SET is_chirpy_red = $2,
     updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at
 `
 
-type UpdateUserEmailPasswordParams struct {
+func (q *Queries) SetUserChirpyRed(ctx context.Context, arg SetUserChirpyRedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserChirpyRed, arg.ID, arg.IsChirpyRed)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.DeletionRequestedAt,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at
+`
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.DeletionRequestedAt,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = COALESCE(suspended_at, NOW()),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at
+`
+
+func (q *Queries) SuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.DeletionRequestedAt,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, unsuspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.DeletionRequestedAt,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
+	)
+	return i, err
+}
+
+type SetUserChirpyRedParams struct {
+	ID          uuid.UUID
+	IsChirpyRed bool
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET hashed_password = COALESCE($1, hashed_password),
+    username = COALESCE($2, username),
+    display_name = COALESCE($3, display_name),
+    bio = COALESCE($4, bio),
+    updated_at = NOW()
+WHERE id = $5
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at
+`
+
+type UpdateUserParams struct {
+	HashedPassword sql.NullString
+	Username       sql.NullString
+	DisplayName    sql.NullString
+	Bio            sql.NullString
 	ID             uuid.UUID
-	Email          string
-	HashedPassword string
 }
 
-func (q *Queries) UpdateUserEmailPassword(ctx context.Context, arg UpdateUserEmailPasswordParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUserEmailPassword, arg.ID, arg.Email, arg.HashedPassword)
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.HashedPassword,
+		arg.Username,
+		arg.DisplayName,
+		arg.Bio,
+		arg.ID,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -94,6 +469,13 @@ func (q *Queries) UpdateUserEmailPassword(ctx context.Context, arg UpdateUserEma
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.DeletionRequestedAt,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
@@ -103,7 +485,7 @@ UPDATE users
 SET is_chirpy_red = TRUE,
     updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, deletion_requested_at, email_verified_at, role, suspended_at
 `
 
 func (q *Queries) UpgradeUserChirpyRed(ctx context.Context, id uuid.UUID) (User, error) {
@@ -116,6 +498,35 @@ func (q *Queries) UpgradeUserChirpyRed(ctx context.Context, id uuid.UUID) (User,
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.DeletionRequestedAt,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.SuspendedAt,
 	)
 	return i, err
 }
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+    AND email = $2
+    AND email_verified_at IS NULL
+`
+
+type VerifyUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, verifyUserEmail, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
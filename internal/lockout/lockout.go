@@ -0,0 +1,134 @@
+// Package lockout throttles repeated failures, such as wrong passwords,
+// with exponential backoff and a temporary lockout.
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Result is the outcome of Store.Attempt.
+type Result struct {
+	// Allowed is false when the key was still locked. The attempt wasn't
+	// counted then.
+	Allowed     bool
+	Failures    int
+	LockedUntil time.Time
+}
+
+// Store keeps failure counters. MemoryStore suits a single server;
+// PostgresStore shares the counters between several.
+type Store interface {
+	// Attempt counts an attempt at now as a failure, unless key is locked
+	// until after now, and locks key for delays[n-1] after the n-th one,
+	// or for the last delay once n is past the end. The count starts over
+	// when the previous failure is before windowStart. Checking the lock
+	// and counting happen as one step, so concurrent attempts can't all
+	// get past the same lock.
+	Attempt(ctx context.Context, key string, now, windowStart time.Time, delays []time.Duration) (Result, error)
+	// Refund takes back one counted failure, after an attempt succeeded.
+	Refund(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+	// Purge forgets keys with no failure or lock after before.
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Policy decides how long a key waits after each failure. The first
+// FreeAttempts failures cost nothing, the ones after that double the wait
+// from BaseDelay up to MaxDelay, and from LockoutThreshold failures on
+// every failure locks the key for LockoutDuration.
+type Policy struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// Window is how long a failure counts. It should be longer than
+	// LockoutDuration, or a lockout would reset the count it came from.
+	Window time.Duration
+}
+
+// Delay is the wait after the given number of failures, and whether that
+// wait is a lockout.
+func (p Policy) Delay(failures int) (time.Duration, bool) {
+	if p.LockoutThreshold > 0 && failures >= p.LockoutThreshold {
+		return p.LockoutDuration, true
+	}
+	if failures <= p.FreeAttempts {
+		return 0, false
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay), false
+}
+
+// schedule lists Delay for 1, 2, ... failures up to the first count after
+// which the delay no longer changes, which is what Store.Attempt takes.
+func (p Policy) schedule() []time.Duration {
+	var delays []time.Duration
+	for failures := 1; ; failures++ {
+		delay, locked := p.Delay(failures)
+		delays = append(delays, delay)
+		if locked || (p.LockoutThreshold <= 0 && failures > p.FreeAttempts && delay >= p.MaxDelay) {
+			return delays
+		}
+	}
+}
+
+// Limiter applies a Policy to the keys in a Store. Keys are shared with
+// every other Limiter on the same Store, so each should use its own prefix.
+type Limiter struct {
+	store  Store
+	policy Policy
+	delays []time.Duration
+	now    func() time.Time
+
+	// OnLockout, when set, is called each time an attempt brings a key to
+	// a lockout.
+	OnLockout func(key string, failures int, until time.Time)
+}
+
+func New(store Store, policy Policy) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		delays: policy.schedule(),
+		now:    time.Now,
+	}
+}
+
+// Attempt is called before trying something that can fail. Every attempt
+// counts as a failure until Refund or Reset says otherwise. It returns how
+// long key has to wait when it may not try now, which is zero otherwise.
+func (l *Limiter) Attempt(ctx context.Context, key string) (time.Duration, error) {
+	now := l.now()
+
+	result, err := l.store.Attempt(ctx, key, now, now.Add(-l.policy.Window), l.delays)
+	if err != nil {
+		return 0, err
+	}
+
+	if !result.Allowed {
+		// the lock can run out between the store refusing and now
+		return max(result.LockedUntil.Sub(now), time.Second), nil
+	}
+
+	if _, locked := l.policy.Delay(result.Failures); locked && l.OnLockout != nil {
+		l.OnLockout(key, result.Failures, result.LockedUntil)
+	}
+	return 0, nil
+}
+
+// Refund takes back the failure an allowed Attempt counted, for attempts
+// that succeeded but shouldn't clear the key's earlier failures.
+func (l *Limiter) Refund(ctx context.Context, key string) error {
+	return l.store.Refund(ctx, key)
+}
+
+// Reset clears key, after a successful attempt or to unlock it by hand.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
@@ -0,0 +1,221 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:     2,
+	BaseDelay:        time.Second,
+	MaxDelay:         8 * time.Second,
+	LockoutThreshold: 8,
+	LockoutDuration:  15 * time.Minute,
+	Window:           time.Hour,
+}
+
+func newTestLimiter(now *time.Time) *Limiter {
+	l := New(NewMemoryStore(), testPolicy)
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestPolicyDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+		locked   bool
+	}{
+		{1, 0, false},
+		{2, 0, false},
+		{3, time.Second, false},
+		{4, 2 * time.Second, false},
+		{5, 4 * time.Second, false},
+		{6, 8 * time.Second, false},
+		{7, 8 * time.Second, false},
+		{8, 15 * time.Minute, true},
+		{20, 15 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		got, locked := testPolicy.Delay(tt.failures)
+		if got != tt.want || locked != tt.locked {
+			t.Errorf("Delay(%d) = %v, %v, want %v, %v", tt.failures, got, locked, tt.want, tt.locked)
+		}
+	}
+}
+
+func TestPolicySchedule(t *testing.T) {
+	delays := testPolicy.schedule()
+	if len(delays) != testPolicy.LockoutThreshold {
+		t.Fatalf("schedule has %d delays, want %d", len(delays), testPolicy.LockoutThreshold)
+	}
+	for i, delay := range delays {
+		want, _ := testPolicy.Delay(i + 1)
+		if delay != want {
+			t.Errorf("schedule[%d] = %v, want %v", i, delay, want)
+		}
+	}
+
+	noLockout := testPolicy
+	noLockout.LockoutThreshold = 0
+	delays = noLockout.schedule()
+	if last := delays[len(delays)-1]; last != noLockout.MaxDelay {
+		t.Errorf("schedule without a lockout ends with %v, want %v", last, noLockout.MaxDelay)
+	}
+}
+
+func TestLimiter_Backoff(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+
+	for i := 0; i < 3; i++ {
+		wait, err := l.Attempt(ctx, "account:jane")
+		if err != nil || wait != 0 {
+			t.Fatalf("Attempt #%d = %v, %v, want it allowed", i+1, wait, err)
+		}
+	}
+
+	// the third failure started a one second delay
+	wait, err := l.Attempt(ctx, "account:jane")
+	if err != nil || wait != time.Second {
+		t.Fatalf("Attempt #4 = %v, %v, want a 1s wait", wait, err)
+	}
+
+	wait, _ = l.Attempt(ctx, "account:john")
+	if wait != 0 {
+		t.Fatalf("Attempt on another key = %v, want it allowed", wait)
+	}
+
+	now = now.Add(time.Second)
+	wait, _ = l.Attempt(ctx, "account:jane")
+	if wait != 0 {
+		t.Fatalf("Attempt after the delay = %v, want it allowed", wait)
+	}
+}
+
+func TestLimiter_Lockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+
+	var lockedKey string
+	var lockedUntil time.Time
+	l.OnLockout = func(key string, failures int, until time.Time) {
+		lockedKey, lockedUntil = key, until
+	}
+
+	for i := 0; i < testPolicy.LockoutThreshold; i++ {
+		wait, err := l.Attempt(ctx, "ip:203.0.113.7")
+		if err != nil || wait != 0 {
+			t.Fatalf("Attempt #%d = %v, %v, want it allowed", i+1, wait, err)
+		}
+		now = now.Add(time.Minute)
+	}
+
+	if lockedKey != "ip:203.0.113.7" {
+		t.Fatalf("OnLockout key = %q, want %q", lockedKey, "ip:203.0.113.7")
+	}
+
+	wait, _ := l.Attempt(ctx, "ip:203.0.113.7")
+	if want := lockedUntil.Sub(now); wait != want {
+		t.Fatalf("Attempt while locked = %v, want %v", wait, want)
+	}
+
+	err := l.Reset(ctx, "ip:203.0.113.7")
+	if err != nil {
+		t.Fatalf("Reset returned an error: %v", err)
+	}
+	wait, _ = l.Attempt(ctx, "ip:203.0.113.7")
+	if wait != 0 {
+		t.Fatalf("Attempt after Reset = %v, want it allowed", wait)
+	}
+}
+
+// a burst of attempts can't all get past the check before any of them is
+// counted
+func TestLimiter_ConcurrentAttempts(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := l.Attempt(ctx, "account:jane")
+			if err == nil && wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the free attempts, plus the one that starts the first delay
+	if want := testPolicy.FreeAttempts + 1; allowed != want {
+		t.Fatalf("%d concurrent attempts were allowed, want %d", allowed, want)
+	}
+}
+
+func TestLimiter_Refund(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+
+	// successful attempts that are refunded never add up to a delay
+	for i := 0; i < 10; i++ {
+		wait, err := l.Attempt(ctx, "ip:203.0.113.7")
+		if err != nil || wait != 0 {
+			t.Fatalf("Attempt #%d = %v, %v, want it allowed", i+1, wait, err)
+		}
+		l.Refund(ctx, "ip:203.0.113.7")
+	}
+}
+
+func TestLimiter_WindowExpires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&now)
+
+	for i := 0; i < 3; i++ {
+		l.Attempt(ctx, "account:jane")
+	}
+
+	now = now.Add(testPolicy.Window + time.Minute)
+	for i := 0; i < 3; i++ {
+		wait, err := l.Attempt(ctx, "account:jane")
+		if err != nil || wait != 0 {
+			t.Fatalf("Attempt #%d after the window = %v, %v, want it allowed", i+1, wait, err)
+		}
+	}
+}
+
+func TestMemoryStore_Purge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	delays := []time.Duration{0}
+	s := NewMemoryStore()
+
+	s.Attempt(ctx, "old", now.Add(-2*time.Hour), now.Add(-3*time.Hour), delays)
+	s.Attempt(ctx, "locked", now.Add(-2*time.Hour), now.Add(-3*time.Hour), []time.Duration{3 * time.Hour})
+	s.Attempt(ctx, "recent", now, now.Add(-time.Hour), delays)
+
+	purged, err := s.Purge(ctx, now.Add(-time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("Purge = %d, %v, want 1", purged, err)
+	}
+
+	for key, want := range map[string]int{"old": 0, "locked": 1, "recent": 1} {
+		if got := s.entries[key].failures; got != want {
+			t.Errorf("%s has %d failures after Purge, want %d", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+// MemoryStore keeps counters in the process. They are lost on restart and
+// not shared between servers.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *MemoryStore) Attempt(ctx context.Context, key string, now, windowStart time.Time, delays []time.Duration) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[key]
+	if now.Before(entry.lockedUntil) {
+		return Result{Failures: entry.failures, LockedUntil: entry.lockedUntil}, nil
+	}
+
+	if entry.lastFailureAt.Before(windowStart) {
+		entry.failures = 0
+	}
+	entry.failures++
+	entry.lastFailureAt = now
+	entry.lockedUntil = now.Add(delays[min(entry.failures, len(delays))-1])
+	s.entries[key] = entry
+
+	return Result{Allowed: true, Failures: entry.failures, LockedUntil: entry.lockedUntil}, nil
+}
+
+func (s *MemoryStore) Refund(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	entry.failures = max(entry.failures-1, 0)
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for key, entry := range s.entries {
+		if entry.lastFailureAt.Before(before) && entry.lockedUntil.Before(before) {
+			delete(s.entries, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
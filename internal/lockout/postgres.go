@@ -0,0 +1,70 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kn1ghtm0nster/internal/database"
+)
+
+// PostgresStore keeps counters in the login_throttles table, so every
+// server sees the same ones.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Attempt(ctx context.Context, key string, now, windowStart time.Time, delays []time.Duration) (Result, error) {
+	delaysMs := make([]int64, len(delays))
+	for i, delay := range delays {
+		delaysMs[i] = delay.Milliseconds()
+	}
+
+	// the upsert only updates rows that aren't locked, and the row lock
+	// it takes makes concurrent attempts on one key wait their turn
+	attempt, err := s.db.RecordLoginAttempt(ctx, database.RecordLoginAttemptParams{
+		Key:         key,
+		AttemptedAt: now,
+		DelaysMs:    delaysMs,
+		WindowStart: windowStart,
+	})
+	if err == nil {
+		return Result{
+			Allowed:     true,
+			Failures:    int(attempt.Failures),
+			LockedUntil: attempt.LockedUntil.Time,
+		}, nil
+	}
+	if err != sql.ErrNoRows {
+		return Result{}, err
+	}
+
+	throttle, err := s.db.GetLoginThrottle(ctx, key)
+	if err != nil {
+		// reset since the attempt was refused; refuse it anyway
+		if err == sql.ErrNoRows {
+			return Result{}, nil
+		}
+		return Result{}, err
+	}
+	return Result{
+		Failures:    int(throttle.Failures),
+		LockedUntil: throttle.LockedUntil.Time,
+	}, nil
+}
+
+func (s *PostgresStore) Refund(ctx context.Context, key string) error {
+	return s.db.RefundLoginFailure(ctx, key)
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	return s.db.DeleteLoginThrottle(ctx, key)
+}
+
+func (s *PostgresStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return s.db.PurgeLoginThrottles(ctx, before)
+}
@@ -0,0 +1,162 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSendTimeout bounds a send whose context has no earlier deadline.
+const DefaultSendTimeout = 30 * time.Second
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends a plain text email.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPMailer sends mail through an SMTP relay. Auth may be nil for relays
+// that don't require it. Timeout bounds each send, so a relay that stops
+// answering can't hold up the request sending the mail.
+type SMTPMailer struct {
+	Addr    string
+	From    string
+	Auth    smtp.Auth
+	Timeout time.Duration
+}
+
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{
+		Addr:    addr,
+		From:    from,
+		Auth:    auth,
+		Timeout: DefaultSendTimeout,
+	}
+}
+
+// Send does what smtp.SendMail does, on a connection that is closed once
+// ctx is done or Timeout has passed.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if m.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// unblocks a read or write in progress when ctx is canceled early
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+	if m.Auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		err = c.Auth(m.Auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(m.From)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(msg.To)
+	if err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatMessage(m.From, msg))
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// LogMailer writes every message to W instead of sending it. It is meant
+// for development and tests, where W can be a file or a buffer.
+type LogMailer struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+func NewLogMailer(w io.Writer) *LogMailer {
+	return &LogMailer{W: w}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.W, "%s\n", formatMessage("chirpy", msg))
+	return err
+}
+
+func formatMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", stripNewlines(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", stripNewlines(msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	return []byte(b.String())
+}
+
+// stripNewlines keeps user supplied values from adding headers of their own.
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,134 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf)
+
+	err := m.Send(context.Background(), Message{
+		To:      "jane@example.com",
+		Subject: "Hello",
+		Body:    "Welcome to Chirpy",
+	})
+	if err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"To: jane@example.com\r\n", "Subject: Hello\r\n", "\r\n\r\nWelcome to Chirpy"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Send output %q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestFormatMessage_HeaderInjection(t *testing.T) {
+	msg := formatMessage("chirpy@example.com", Message{
+		To:      "jane@example.com\r\nBcc: eve@example.com",
+		Subject: "Hi\nBcc: eve@example.com",
+		Body:    "body",
+	})
+
+	if strings.Contains(string(msg), "\r\nBcc:") || strings.Contains(string(msg), "\nBcc:") {
+		t.Fatalf("formatMessage let a header through: %q", msg)
+	}
+}
+
+// a relay that accepts the connection but never greets must not hang Send
+func TestSMTPMailer_Timeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	m := NewSMTPMailer(ln.Addr().String(), "chirpy@example.com", "", "")
+	m.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	err = m.Send(context.Background(), Message{To: "jane@example.com", Subject: "Hello", Body: "body"})
+	if err == nil {
+		t.Fatal("Send returned no error for a relay that never answered")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Send took %v, expected it to give up after its timeout", elapsed)
+	}
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost\r\n"))
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				received <- data.String()
+				conn.Write([]byte("250 OK\r\n"))
+			case inData:
+				data.WriteString(line)
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				conn.Write([]byte("354 Go ahead\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				conn.Write([]byte("221 Bye\r\n"))
+				return
+			default:
+				conn.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+
+	m := NewSMTPMailer(ln.Addr().String(), "chirpy@example.com", "", "")
+	err = m.Send(context.Background(), Message{To: "jane@example.com", Subject: "Hello", Body: "Welcome to Chirpy"})
+	if err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if !strings.Contains(msg, "Subject: Hello\r\n") || !strings.Contains(msg, "Welcome to Chirpy") {
+			t.Fatalf("relay received %q", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("relay received no message")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/kn1ghtm0nster/internal/auth"
+)
+
+// keyringFromEnv signs access tokens with the PEM private key in
+// JWT_SIGNING_KEY and also accepts tokens signed by any key listed in
+// JWT_VERIFICATION_KEYS, so a retired key can stay trusted until its tokens
+// have expired. Without a signing key, everything stays HS256 with SECRET.
+// SECRET is required either way, since it still signs two-factor and email
+// verification tokens.
+//
+// Once a signing key is set, HS256 tokens are refused: anyone who has
+// SECRET could mint them. JWT_ALLOW_HS256=true accepts them anyway, which
+// is meant for the hour after switching over, while access tokens issued
+// before it are still valid; unset it again afterwards.
+func keyringFromEnv(secret string) *auth.Keyring {
+	signingKeyFile := os.Getenv("JWT_SIGNING_KEY")
+	if signingKeyFile == "" {
+		return auth.NewHMACKeyring(secret)
+	}
+
+	var verificationKeyFiles []string
+	for _, file := range strings.Split(os.Getenv("JWT_VERIFICATION_KEYS"), ",") {
+		file = strings.TrimSpace(file)
+		if file != "" {
+			verificationKeyFiles = append(verificationKeyFiles, file)
+		}
+	}
+
+	keyring, err := auth.LoadKeyring(signingKeyFile, verificationKeyFiles)
+	if err != nil {
+		log.Fatal("Error loading JWT keys:", err)
+	}
+
+	if os.Getenv("JWT_ALLOW_HS256") == "true" && secret != "" {
+		log.Println("Warning: JWT_ALLOW_HS256 is set, access tokens signed with SECRET are still accepted")
+		keyring.AllowHMAC(secret)
+	}
+	return keyring
+}
+
+// jwksHandler publishes the public keys access tokens are signed with so
+// other services can verify them without calling us.
+func (cfg *apiConfig) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	resp := cfg.keyring.JWKS()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/utils"
+)
+
+func (cfg *apiConfig) likeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID := requirePrincipal(r).UserID
+
+	_, err = cfg.db.GetChirpById(r.Context(), database.GetChirpByIdParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// liking twice is a no-op; like_count is maintained by a trigger on
+	// chirp_likes so it only moves when a row is actually inserted
+	_, err = cfg.db.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unlikeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID := requirePrincipal(r).UserID
+
+	_, err = cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getUserLikesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	limit, cursor, err := parsePageParams(r, utils.CursorKindTime)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	_, err = cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	afterCreatedAt, afterID := cursorArgs(cursor)
+
+	viewerID := optionalViewerID(r)
+
+	// fetch one extra row so we know whether there is another page
+	likes, err := cfg.db.ListChirpsLikedByUser(r.Context(), database.ListChirpsLikedByUserParams{
+		UserID:         userID,
+		ViewerID:       viewerID,
+		AfterCreatedAt: afterCreatedAt,
+		AfterID:        afterID,
+		PageSize:       int32(limit + 1),
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := ChirpPage{}
+
+	// pages follow the order the chirps were liked in, not when they
+	// were posted
+	if len(likes) > limit {
+		likes = likes[:limit]
+		last := likes[len(likes)-1]
+		resp.NextCursor = utils.EncodeCursor(utils.Cursor{
+			Kind:      utils.CursorKindTime,
+			CreatedAt: last.LikedAt,
+			ID:        last.Chirp.ID,
+		})
+	}
+
+	chirps := make([]database.Chirp, len(likes))
+	for i, like := range likes {
+		chirps[i] = like.Chirp
+	}
+
+	resp.Chirps, err = cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/internal/lockout"
+)
+
+// loginThrottleWindow is how long a failed attempt counts against an
+// account or address. Every policy on the store uses it, so one purge fits all.
+const loginThrottleWindow = time.Hour
+
+// accountLoginPolicy applies per email address, and per account to
+// two-factor codes.
+var accountLoginPolicy = lockout.Policy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 10,
+	LockoutDuration:  15 * time.Minute,
+	Window:           loginThrottleWindow,
+}
+
+// ipLoginPolicy is looser since many users can share an address.
+var ipLoginPolicy = lockout.Policy{
+	FreeAttempts:     20,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 100,
+	LockoutDuration:  15 * time.Minute,
+	Window:           loginThrottleWindow,
+}
+
+// loginThrottleStoreFromEnv keeps counters in memory unless
+// LOGIN_THROTTLE_STORE=postgres, which is needed to share them between
+// servers.
+func loginThrottleStoreFromEnv(db *database.Queries) lockout.Store {
+	switch store := os.Getenv("LOGIN_THROTTLE_STORE"); store {
+	case "", "memory":
+		return lockout.NewMemoryStore()
+	case "postgres":
+		return lockout.NewPostgresStore(db)
+	default:
+		log.Fatalf("Invalid LOGIN_THROTTLE_STORE %q, want memory or postgres", store)
+		return nil
+	}
+}
+
+func newLoginLimiter(store lockout.Store, policy lockout.Policy) *lockout.Limiter {
+	limiter := lockout.New(store, policy)
+	limiter.OnLockout = func(key string, failures int, until time.Time) {
+		log.Printf("Locked out %s after %d failed attempts until %s", key, failures, until.Format(time.RFC3339))
+	}
+	return limiter
+}
+
+// hashedEmail keeps addresses out of throttle keys, so neither the store
+// nor the lockout log holds them.
+func hashedEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + hashedEmail(email)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+func twoFactorThrottleKey(userID uuid.UUID) string {
+	return "2fa:" + userID.String()
+}
+
+// throttledAttempt is one counter an attempt is held against.
+type throttledAttempt struct {
+	limiter *lockout.Limiter
+	key     string
+}
+
+// beginAttempts counts an attempt against each counter before it is
+// tried, and returns how long to wait when one of them refuses it. A
+// refused attempt isn't held against the counters that did let it through.
+func beginAttempts(ctx context.Context, attempts []throttledAttempt) (time.Duration, error) {
+	for i, attempt := range attempts {
+		wait, err := attempt.limiter.Attempt(ctx, attempt.key)
+		if err != nil {
+			return 0, err
+		}
+		if wait > 0 {
+			for _, counted := range attempts[:i] {
+				err = counted.limiter.Refund(ctx, counted.key)
+				if err != nil {
+					return 0, err
+				}
+			}
+			return wait, nil
+		}
+	}
+	return 0, nil
+}
+
+func writeTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"sort"
+	"strconv"
 	"sync/atomic"
 	"time"
 
@@ -18,6 +20,8 @@ import (
 	"github.com/kn1ghtm0nster/handlers"
 	"github.com/kn1ghtm0nster/internal/auth"
 	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/internal/lockout"
+	"github.com/kn1ghtm0nster/internal/mailer"
 	"github.com/kn1ghtm0nster/utils"
 )
 
@@ -27,28 +31,63 @@ type User struct {
 	UpdatedAt 	time.Time 	`json:"updated_at"`
 	Email 		string 		`json:"email"`
 	IsChirpyRed bool		`json:"is_chirpy_red"`
+	Username	string		`json:"username,omitempty"`
+	DisplayName	string		`json:"display_name"`
+	Bio			string		`json:"bio"`
+	EmailVerified bool		`json:"email_verified"`
+	Role		string		`json:"role"`
 }
 
 type CreateUserRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-type UpdateUserRequest struct {
 	Email       string `json:"email"`
 	Password    string `json:"password"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
 }
 
 type CreateChirpRequest struct {
-	Body   string    `json:"body"`
+	Body      string     `json:"body"`
+	ParentID  *uuid.UUID `json:"parent_id"`
+	RechirpOf *uuid.UUID `json:"rechirp_of"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 type Chirp struct {
-	ID 	  		uuid.UUID `json:"id"`
-	CreatedAt 	time.Time `json:"created_at"`
-	UpdatedAt 	time.Time `json:"updated_at"`
-	Body      	string    `json:"body"`
-	UserID    	uuid.UUID `json:"user_id"`
+	ID 	  		uuid.UUID  `json:"id"`
+	CreatedAt 	time.Time  `json:"created_at"`
+	UpdatedAt 	time.Time  `json:"updated_at"`
+	Body      	string     `json:"body"`
+	UserID    	uuid.UUID  `json:"user_id"`
+	ParentID	*uuid.UUID `json:"parent_id,omitempty"`
+	LikeCount	int32      `json:"like_count"`
+	LikedByMe	*bool      `json:"liked_by_me,omitempty"`
+	RechirpOf	*EmbeddedChirp `json:"rechirp_of,omitempty"`
+	PublishAt	*time.Time `json:"publish_at,omitempty"`
+}
+
+// EmbeddedChirp is the original chirp shown inside a rechirp. When the
+// original has been deleted only its ID is kept and Available is false.
+type EmbeddedChirp struct {
+	ID        uuid.UUID  `json:"id"`
+	Available bool       `json:"available"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Body      string     `json:"body,omitempty"`
+	UserID    *uuid.UUID `json:"user_id,omitempty"`
+}
+
+// ChirpThread is a chirp with its replies. A deleted chirp that still has
+// replies stays in as a placeholder with Deleted set, which only keeps its
+// ID, creation time and parent, so the thread keeps its shape.
+type ChirpThread struct {
+	Chirp
+	Deleted bool           `json:"deleted,omitempty"`
+	Replies []*ChirpThread `json:"replies"`
+}
+
+type ChirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
 }
 
 type LoginRequest struct {
@@ -64,6 +103,7 @@ type LoginResponse struct {
 	Token		string		`json:"token,omitempty"`
 	RefreshToken string     `json:"refresh_token,omitempty"`
 	IsChirpyRed bool		`json:"is_chirpy_red"`
+	Username	string		`json:"username,omitempty"`
 }
 
 type WebHookData struct {
@@ -75,19 +115,199 @@ type WebHook struct {
 	Data WebHookData `json:"data"`
 }
 
+const (
+	defaultThreadDepth = 10
+	maxThreadDepth     = 50
+)
+
+const (
+	defaultEditWindow    = 15 * time.Minute
+	defaultRedEditWindow = time.Hour
+	defaultPurgeInterval = time.Hour
+	chirpRestoreWindow   = 30 * 24 * time.Hour
+	defaultDeletionGrace = 30 * 24 * time.Hour
+)
+
 type apiConfig struct {
 	fileserverHits 	atomic.Int32
 	db 				*database.Queries
+	authDB			authStore
+	dbConn			*sql.DB
 	platform 		string
 	secret 			string
+	keyring			*auth.Keyring
 	polkaKey		string
+	editWindow		time.Duration
+	redEditWindow	time.Duration
+	deletionGrace	time.Duration
+	mailer			mailer.Mailer
+	requireEmailVerification bool
+	loginThrottles	lockout.Store
+	accountLimiter	*lockout.Limiter
+	ipLimiter		*lockout.Limiter
+	resetEmailLimiter	*lockout.Limiter
+	resetIPLimiter	*lockout.Limiter
+	passwordResets	chan string
+}
+
+// durationFromEnv reads a time.ParseDuration value such as "15m" from the
+// environment, falling back when the variable is unset.
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Fatalf("Invalid duration for %s: %v", key, err)
+	}
+	return duration
+}
+
+func userResponse(user database.User) User {
+	return User{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+		Username:    user.Username.String,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Role:        user.Role,
+	}
+}
+
+func chirpResponse(chirp database.Chirp) Chirp {
+	resp := Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		LikeCount: chirp.LikeCount,
+	}
+	if chirp.ParentID.Valid {
+		resp.ParentID = &chirp.ParentID.UUID
+	}
+	if chirp.RechirpOfID.Valid {
+		resp.RechirpOf = &EmbeddedChirp{ID: chirp.RechirpOfID.UUID}
+	}
+	if chirp.PublishAt.Valid {
+		resp.PublishAt = &chirp.PublishAt.Time
+	}
+	return resp
+}
+
+// chirpResponses maps chirps to their JSON form, embeds the originals of
+// any rechirps and, when a viewer is known, marks which of them the viewer
+// has liked.
+func (cfg *apiConfig) chirpResponses(ctx context.Context, chirps []database.Chirp, viewerID uuid.NullUUID) ([]Chirp, error) {
+	resp := make([]Chirp, len(chirps))
+	ids := make([]uuid.UUID, len(chirps))
+	var originalIDs []uuid.UUID
+	for i, chirp := range chirps {
+		resp[i] = chirpResponse(chirp)
+		ids[i] = chirp.ID
+		if chirp.RechirpOfID.Valid {
+			originalIDs = append(originalIDs, chirp.RechirpOfID.UUID)
+		}
+	}
+
+	if len(originalIDs) > 0 {
+		originals, err := cfg.db.GetChirpsByIds(ctx, database.GetChirpsByIdsParams{
+			Ids:      originalIDs,
+			ViewerID: viewerID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		found := make(map[uuid.UUID]database.Chirp, len(originals))
+		for _, original := range originals {
+			found[original.ID] = original
+		}
+
+		// originals that no longer exist keep the unavailable placeholder
+		// set by chirpResponse
+		for i := range resp {
+			if resp[i].RechirpOf == nil {
+				continue
+			}
+			original, ok := found[resp[i].RechirpOf.ID]
+			if !ok {
+				continue
+			}
+			resp[i].RechirpOf = &EmbeddedChirp{
+				ID:        original.ID,
+				Available: true,
+				CreatedAt: &original.CreatedAt,
+				Body:      original.Body,
+				UserID:    &original.UserID,
+			}
+		}
+	}
+
+	if !viewerID.Valid || len(chirps) == 0 {
+		return resp, nil
+	}
+
+	likedIDs, err := cfg.db.ListLikedChirpIds(ctx, database.ListLikedChirpIdsParams{
+		UserID:   viewerID.UUID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	liked := make(map[uuid.UUID]bool, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	for i := range resp {
+		likedByMe := liked[resp[i].ID]
+		resp[i].LikedByMe = &likedByMe
+	}
+	return resp, nil
+}
+
+// parsePageParams reads the `limit` and `cursor` query parameters shared by
+// every paginated endpoint. The returned cursor is nil on the first page,
+// and cursors of any other kind than the endpoint's are refused.
+func parsePageParams(r *http.Request, kind string) (int, *utils.Cursor, error) {
+	limit, err := utils.ParseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rawCursor := r.URL.Query().Get("cursor")
+	if rawCursor == "" {
+		return limit, nil, nil
+	}
+
+	cursor, err := utils.DecodeCursor(rawCursor, kind)
+	if err != nil {
+		return 0, nil, err
+	}
+	return limit, &cursor, nil
+}
+
+func cursorArgs(cursor *utils.Cursor) (sql.NullTime, uuid.NullUUID) {
+	if cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: cursor.ID, Valid: true}
 }
 
 func (cfg *apiConfig) polkaWebhookHandler(w http.ResponseWriter, r *http.Request) {
 	var webhookReq WebHook
 
 	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil || apiKey != cfg.polkaKey {
+	// compare in constant time so the key can't be guessed byte by byte,
+	// and never accept an empty key when POLKA_KEY isn't configured
+	if err != nil || cfg.polkaKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.polkaKey)) != 1 {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -140,13 +360,36 @@ func (cfg *apiConfig) metricsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) resetMetricsHandler(w http.ResponseWriter, r *http.Request) {
-	// check platform
+	// wiping every account stays a dev-only operation, even for admins
 	if cfg.platform != "dev" {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
 	// reset users table
-	err := cfg.db.DeleteAllUsers(r.Context())
+	err = qtx.DeleteAllUsers(r.Context())
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// the audit log has no foreign keys, so this entry outlives the reset
+	err = recordAudit(r, qtx, "users.reset", uuid.NullUUID{}, nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -185,25 +428,53 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// a username is optional at signup, but must be valid when given
+	var username sql.NullString
+	if req.Username != "" {
+		err = utils.ValidateUsername(req.Username)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		username = sql.NullString{String: req.Username, Valid: true}
+	}
+
+	err = validateProfile(req.DisplayName, req.Bio)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// create the new user
 	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
 		Email: 			req.Email,
 		HashedPassword: hashedPassword,
+		Username:       username,
+		DisplayName:    req.DisplayName,
+		Bio:            req.Bio,
 	})
 	if err != nil {
+		if isUniqueViolation(err, usernameUniqueIndex) {
+			http.Error(w, "Username is already taken", http.StatusConflict)
+			return
+		}
+		if isUniqueViolation(err, emailUniqueConstraint) {
+			cfg.handleDuplicateSignup(w, r, req, username)
+			return
+		}
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	// map returned user to response struct
-	resp := User{
-		ID:        user.ID,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		Email:     user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+	// a failed send isn't fatal, the user can ask for another one
+	err = cfg.sendVerificationEmail(r.Context(), user)
+	if err != nil {
+		log.Println("Error sending verification email:", err)
 	}
 
+	// map returned user to response struct
+	resp := userResponse(user)
+
 	// send response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -213,55 +484,140 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request) {
 	var req CreateChirpRequest
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
+	userID := requirePrincipal(r).UserID
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
-	err = json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
-		return
+	if cfg.requireEmailVerification {
+		user, err := cfg.db.GetUserById(r.Context(), userID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !user.EmailVerifiedAt.Valid {
+			http.Error(w, "Email address is not verified", http.StatusForbidden)
+			return
+		}
 	}
 
-	if req.Body == "" {
+	// a plain rechirp has no text of its own
+	if req.Body == "" && req.RechirpOf == nil {
 		http.Error(w, "Body is required", http.StatusBadRequest)
 		return
 	}
 
+	// scheduled chirps stay hidden from everyone but the author until
+	// publish_at; that is decided at query time, not by a background job
+	var publishAt sql.NullTime
+	if req.PublishAt != nil {
+		if !req.PublishAt.After(time.Now()) {
+			http.Error(w, "publish_at must be in the future", http.StatusBadRequest)
+			return
+		}
+		publishAt = sql.NullTime{Time: *req.PublishAt, Valid: true}
+	}
+
 	// clean the body
 	cleanedBody := utils.CleanProfanity(req.Body)
 
-	// ensure length is less than 140 chars
+	// ensure length is less than 140 chars; for a quote-chirp this only
+	// counts the quote text, not the embedded original
 	if len(cleanedBody) > 140 {
 		http.Error(w, "Chirp is too long", http.StatusBadRequest)
 		return
 	}
 
-	newChirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleanedBody,
-		UserID: userID,
+	// replies must point at a chirp that exists
+	var parentID uuid.NullUUID
+	if req.ParentID != nil {
+		parent, err := cfg.db.GetChirpById(r.Context(), database.GetChirpByIdParams{
+			ID:       *req.ParentID,
+			ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Parent chirp not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		parentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+
+	var rechirpOfID uuid.NullUUID
+	if req.RechirpOf != nil {
+		original, err := cfg.db.GetChirpById(r.Context(), database.GetChirpByIdParams{
+			ID:       *req.RechirpOf,
+			ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Original chirp not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// rechirping a plain rechirp points at the chirp it reposted
+		if original.Body == "" && original.RechirpOfID.Valid {
+			rechirpOfID = original.RechirpOfID
+		} else {
+			rechirpOfID = uuid.NullUUID{UUID: original.ID, Valid: true}
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	newChirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		PublishAt:   publishAt,
+		Body:        cleanedBody,
+		UserID:      userID,
+		ParentID:    parentID,
+		RechirpOfID: rechirpOfID,
 	})
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	resp := Chirp{
-		ID:        newChirp.ID,
-		CreatedAt: newChirp.CreatedAt,
-		UpdatedAt: newChirp.UpdatedAt,
-		Body:      newChirp.Body,
-		UserID:    newChirp.UserID,
+	// tags and mentions are stored with the chirp so the feeds never see
+	// one without the other
+	err = indexChirp(r.Context(), qtx, newChirp)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	chirps, err := cfg.chirpResponses(r.Context(), []database.Chirp{newChirp}, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	resp := chirps[0]
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(resp)
@@ -271,18 +627,45 @@ func (cfg *apiConfig) getAllChirpsHandler(w http.ResponseWriter, r *http.Request
 	authorID := r.URL.Query().Get("author_id")
 	sortBy := r.URL.Query().Get("sort")
 
-	var chirps []database.Chirp
-	var err error
+	limit, cursor, err := parsePageParams(r, utils.CursorKindTime)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
 
+	var author uuid.NullUUID
 	if authorID != "" {
 		parsedID, parseErr := uuid.Parse(authorID)
 		if parseErr != nil {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
-		chirps, err = cfg.db.GetChirpsByAuthorId(r.Context(), parsedID)
+		author = uuid.NullUUID{UUID: parsedID, Valid: true}
+	}
+
+	afterCreatedAt, afterID := cursorArgs(cursor)
+	viewerID := optionalViewerID(r)
+
+	// fetch one extra row so we know whether there is another page
+	pageSize := int32(limit + 1)
+
+	var chirps []database.Chirp
+	if sortBy == "desc" {
+		chirps, err = cfg.db.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+			ViewerID:       viewerID,
+			AuthorID:       author,
+			AfterCreatedAt: afterCreatedAt,
+			AfterID:        afterID,
+			PageSize:       pageSize,
+		})
 	} else {
-		chirps, err = cfg.db.GetAllChirps(r.Context())
+		chirps, err = cfg.db.ListChirpsAsc(r.Context(), database.ListChirpsAscParams{
+			ViewerID:       viewerID,
+			AuthorID:       author,
+			AfterCreatedAt: afterCreatedAt,
+			AfterID:        afterID,
+			PageSize:       pageSize,
+		})
 	}
 
 	if err != nil {
@@ -290,22 +673,116 @@ func (cfg *apiConfig) getAllChirpsHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if sortBy == "desc" {
-		sort.Slice(chirps, func(i, j int) bool {
-			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
+	resp := ChirpPage{}
+
+	if len(chirps) > limit {
+		chirps = chirps[:limit]
+		last := chirps[len(chirps)-1]
+		resp.NextCursor = utils.EncodeCursor(utils.Cursor{
+			Kind:      utils.CursorKindTime,
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
 		})
 	}
 
-	resp := make([]Chirp, len(chirps))
+	resp.Chirps, err = cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
-	for i, chirp := range chirps {
-		resp[i] = Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(chirpListBody(resp, isLegacyChirpList(r)))
+}
+
+// isLegacyChirpList reports whether a GET /api/chirps request sent neither
+// `limit` nor `cursor`, as clients written before pagination do.
+func isLegacyChirpList(r *http.Request) bool {
+	query := r.URL.Query()
+	return !query.Has("limit") && !query.Has("cursor")
+}
+
+// chirpListBody is the page for paginated requests, and the bare array of
+// chirps GET /api/chirps returned before pagination for legacy ones. Legacy
+// clients get the first DefaultPageSize chirps rather than the whole table.
+func chirpListBody(resp ChirpPage, legacy bool) any {
+	if legacy {
+		return resp.Chirps
+	}
+	return resp
+}
+
+func (cfg *apiConfig) searchChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	authorID := r.URL.Query().Get("author_id")
+
+	if query == "" {
+		http.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+
+	limit, cursor, err := parsePageParams(r, utils.CursorKindRank)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	var author uuid.NullUUID
+	if authorID != "" {
+		parsedID, parseErr := uuid.Parse(authorID)
+		if parseErr != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
 		}
+		author = uuid.NullUUID{UUID: parsedID, Valid: true}
+	}
+
+	// results are ordered by relevance, so the cursor carries the rank
+	// instead of the creation time
+	var afterRank sql.NullFloat64
+	var afterID uuid.NullUUID
+	if cursor != nil {
+		afterRank = sql.NullFloat64{Float64: float64(cursor.Rank), Valid: true}
+		afterID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	viewerID := optionalViewerID(r)
+
+	results, err := cfg.db.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Query:     query,
+		ViewerID:  viewerID,
+		AuthorID:  author,
+		AfterRank: afterRank,
+		AfterID:   afterID,
+		PageSize:  int32(limit + 1),
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := ChirpPage{}
+
+	if len(results) > limit {
+		results = results[:limit]
+		last := results[len(results)-1]
+		resp.NextCursor = utils.EncodeCursor(utils.Cursor{
+			Kind: utils.CursorKindRank,
+			ID:   last.Chirp.ID,
+			Rank: last.Rank,
+		})
+	}
+
+	chirps := make([]database.Chirp, len(results))
+	for i, result := range results {
+		chirps[i] = result.Chirp
+	}
+
+	resp.Chirps, err = cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -319,9 +796,15 @@ func (cfg *apiConfig) getChirpByIdHandler(w http.ResponseWriter, r *http.Request
 	parsedId, err := uuid.Parse(chirpID)
 	if err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
 	}
 
-	chirp, err := cfg.db.GetChirpById(r.Context(), parsedId)
+	viewerID := optionalViewerID(r)
+
+	chirp, err := cfg.db.GetChirpById(r.Context(), database.GetChirpByIdParams{
+		ID:       parsedId,
+		ViewerID: viewerID,
+	})
 	// handle not found errors on top of other possible errors
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -332,21 +815,19 @@ func (cfg *apiConfig) getChirpByIdHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	resp := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	chirps, err := cfg.chirpResponses(r.Context(), []database.Chirp{chirp}, viewerID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+	resp := chirps[0]
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(resp)
 }
 
-func (cfg *apiConfig) deleteChirpByIdHandler(w http.ResponseWriter, r *http.Request) {
-	// Implementation for deleting a chirp by ID
+func (cfg *apiConfig) getChirpThreadHandler(w http.ResponseWriter, r *http.Request) {
 	chirpID := r.PathValue("chirpID")
 
 	parsedId, err := uuid.Parse(chirpID)
@@ -355,19 +836,126 @@ func (cfg *apiConfig) deleteChirpByIdHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	maxDepth := defaultThreadDepth
+	if rawDepth := r.URL.Query().Get("depth"); rawDepth != "" {
+		maxDepth, err = strconv.Atoi(rawDepth)
+		if err != nil || maxDepth < 0 {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		if maxDepth > maxThreadDepth {
+			maxDepth = maxThreadDepth
+		}
+	}
+
+	viewerID := optionalViewerID(r)
+
+	rows, err := cfg.db.GetChirpThread(r.Context(), database.GetChirpThreadParams{
+		RootID:   parsedId,
+		ViewerID: viewerID,
+		MaxDepth: int32(maxDepth),
+	})
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	// deleted chirps are only shown as placeholders, so they don't need
+	// rechirps or likes looked up
+	var chirps []database.Chirp
+	for _, row := range rows {
+		if !row.Chirp.DeletedAt.Valid {
+			chirps = append(chirps, row.Chirp)
+		}
+	}
+
+	responses, err := cfg.chirpResponses(r.Context(), chirps, viewerID)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	root := buildChirpThread(rows, responses)
+	if root == nil {
+		http.Error(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(root)
+}
+
+// buildChirpThread nests rows, ordered by depth as GetChirpThread returns
+// them, under the chirp at depth 0. responses holds the live chirps in row
+// order. Deleted chirps become placeholders while something below them is
+// still live, and are left out otherwise. It returns nil when nothing is
+// left to show.
+func buildChirpThread(rows []database.GetChirpThreadRow, responses []Chirp) *ChirpThread {
+	// walking up from the deepest rows, a chirp is kept when it is live or
+	// one of its replies was kept
+	keep := make(map[uuid.UUID]bool, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		chirp := rows[i].Chirp
+		if !chirp.DeletedAt.Valid || keep[chirp.ID] {
+			keep[chirp.ID] = true
+			if rows[i].Depth > 0 {
+				keep[chirp.ParentID.UUID] = true
+			}
+		}
+	}
+
+	// every parent is seen before its replies and can be looked up by ID
+	nodes := make(map[uuid.UUID]*ChirpThread, len(rows))
+	var root *ChirpThread
+	live := 0
+	for _, row := range rows {
+		if !keep[row.Chirp.ID] {
+			continue
+		}
+
+		node := &ChirpThread{Replies: []*ChirpThread{}}
+		if row.Chirp.DeletedAt.Valid {
+			node.Chirp = Chirp{
+				ID:        row.Chirp.ID,
+				CreatedAt: row.Chirp.CreatedAt,
+			}
+			if row.Chirp.ParentID.Valid {
+				node.Chirp.ParentID = &row.Chirp.ParentID.UUID
+			}
+			node.Deleted = true
+		} else {
+			node.Chirp = responses[live]
+			live++
+		}
+		nodes[row.Chirp.ID] = node
+
+		if row.Depth == 0 {
+			root = node
+			continue
+		}
+		parent := nodes[row.Chirp.ParentID.UUID]
+		parent.Replies = append(parent.Replies, node)
+	}
+	return root
+}
+
+func (cfg *apiConfig) deleteChirpByIdHandler(w http.ResponseWriter, r *http.Request) {
+	// Implementation for deleting a chirp by ID
+	chirpID := r.PathValue("chirpID")
+
+	parsedId, err := uuid.Parse(chirpID)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
-	chirp, err := cfg.db.GetChirpById(r.Context(), parsedId)
+	userID := requirePrincipal(r).UserID
+
+	chirp, err := cfg.db.GetChirpById(r.Context(), database.GetChirpByIdParams{
+		ID:       parsedId,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Chirp not found", http.StatusNotFound)
@@ -382,7 +970,9 @@ func (cfg *apiConfig) deleteChirpByIdHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = cfg.db.DeleteChirpById(r.Context(), database.DeleteChirpByIdParams{
+	// the chirp is only tombstoned here; the purge job hard-deletes it
+	// once it can no longer be restored, which detaches its replies
+	err = cfg.db.SoftDeleteChirpById(r.Context(), database.SoftDeleteChirpByIdParams{
 		ID:     parsedId,
 		UserID: userID,
 	})
@@ -394,6 +984,58 @@ func (cfg *apiConfig) deleteChirpByIdHandler(w http.ResponseWriter, r *http.Requ
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (cfg *apiConfig) restoreChirpByIdHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID := r.PathValue("chirpID")
+
+	parsedId, err := uuid.Parse(chirpID)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID := requirePrincipal(r).UserID
+
+	chirp, err := cfg.db.GetDeletedChirpById(r.Context(), parsedId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if chirp.UserID != userID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	restoredChirp, err := cfg.db.RestoreChirpById(r.Context(), database.RestoreChirpByIdParams{
+		ID:           parsedId,
+		UserID:       userID,
+		DeletedAfter: time.Now().Add(-chirpRestoreWindow),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Restore window has expired", http.StatusGone)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	chirps, err := cfg.chirpResponses(r.Context(), []database.Chirp{restoredChirp}, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	resp := chirps[0]
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
 func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	// Implementation for user login
 	var req LoginRequest
@@ -404,9 +1046,31 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// failures count against the address the attempt names as well as the
+	// client, so spreading guesses over many IPs or accounts doesn't help
+	attempts := []throttledAttempt{
+		{cfg.accountLimiter, accountThrottleKey(req.Email)},
+		{cfg.ipLimiter, ipThrottleKey(clientIP(r))},
+	}
+
+	// the attempt is counted as a failure before it is tried, so a burst of
+	// requests can't all get past the check before any of them fails
+	wait, err := beginAttempts(r.Context(), attempts)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
+
 	user, err := cfg.db.GetUserByEmail(r.Context(), req.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
+			// spend the time a wrong password would, so how long this
+			// takes doesn't tell whether the email is registered
+			auth.CheckDummyPasswordHash(req.Password)
 			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 			return
 		}
@@ -420,7 +1084,69 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
+	err = cfg.accountLimiter.Reset(r.Context(), accountThrottleKey(req.Email))
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// the address only gets this attempt back, or one valid account would
+	// clear its count
+	err = cfg.ipLimiter.Refund(r.Context(), ipThrottleKey(clientIP(r)))
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// users with two-factor authentication get a short-lived MFA token
+	// instead, to exchange at /api/login/2fa together with a code
+	totp, err := cfg.db.GetUserTOTP(r.Context(), user.ID)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err == nil && totp.EnabledAt.Valid {
+		mfaToken, err := auth.MakeMFAToken(user.ID, cfg.secret, mfaTokenTTL)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		resp := MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	cfg.completeLogin(w, r, user)
+}
+
+// completeLogin issues the access and refresh tokens once every login
+// step has passed.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	if user.SuspendedAt.Valid {
+		http.Error(w, "Account suspended", http.StatusForbidden)
+		return
+	}
+
+	// logging back in during the grace period keeps the account
+	if user.DeletionRequestedAt.Valid {
+		err := cfg.db.CancelUserDeletion(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// each login starts a new session, which is the refresh token family
+	sessionID := uuid.New()
+
+	token, err := cfg.keyring.MakeSessionJWT(user.ID, sessionID, time.Hour)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -432,9 +1158,13 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createdRefreshToken, err := cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+	// only the hash is stored, the token itself goes back to the client
+	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
 		UserID: user.ID,
-		Token: refreshToken,
+		TokenHash: auth.HashToken(refreshToken),
+		FamilyID: sessionID,
+		UserAgent: clientUserAgent(r),
+		IpAddress: clientIP(r),
 	})
 
 	if err != nil {
@@ -448,8 +1178,9 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt: user.UpdatedAt,
 		Email:     user.Email,
 		Token:    	token,
-		RefreshToken: createdRefreshToken.Token,
+		RefreshToken: refreshToken,
 		IsChirpyRed: user.IsChirpyRed,
+		Username:    user.Username.String,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -465,103 +1196,208 @@ func (cfg *apiConfig) refreshTokenHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// 2. Look up user from refresh token provided
-	user, err := cfg.db.GetUserFromRefreshToken(r.Context(), refreshToken)
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	// 3. Generate new access token for that user (1 hour expiry)
-	newAccessToken, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
+	qtx := cfg.db.WithTx(tx)
+
+	// 2. Look up the refresh token, locking it so two requests can't
+	// rotate the same token at once
+	storedToken, err := qtx.GetRefreshTokenForUpdate(r.Context(), auth.HashToken(refreshToken))
 	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	// 4. Return new access token in response
-	resp := map[string]string{
-		"token": newAccessToken,
+	if storedToken.RevokedAt.Valid {
+		// a token that was already rotated should never be presented
+		// again; if it is, either the client or an attacker holds a
+		// stolen copy, so the whole family is signed out
+		if storedToken.ReplacedBy.Valid {
+			err = qtx.RevokeRefreshTokenFamily(r.Context(), storedToken.FamilyID)
+			if err != nil {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			err = tx.Commit()
+			if err != nil {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			log.Printf("Refresh token reuse detected for user %s, revoked token family %s", storedToken.UserID, storedToken.FamilyID)
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(resp)
-}
-
-func (cfg *apiConfig) revokeRefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
-	// 1. Extract refresh token from Authorization header
-	refreshToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
+	if time.Now().After(storedToken.ExpiresAt) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// 2. Revoke the refresh token in the database
-	err = cfg.db.RevokeRefreshToken(r.Context(), refreshToken)
+	user, err := qtx.GetUserById(r.Context(), storedToken.UserID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (cfg *apiConfig) updateUserEmailPasswordHandler(w http.ResponseWriter, r *http.Request) {
-	var req UpdateUserRequest
-
-	token, err := auth.GetBearerToken(r.Header)
+	// 3. Replace the refresh token with a new one in the same family
+	newRefreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	createdRefreshToken, err := qtx.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(newRefreshToken),
+		FamilyID:  storedToken.FamilyID,
+		UserAgent: clientUserAgent(r),
+		IpAddress: clientIP(r),
+	})
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	err = json.NewDecoder(r.Body).Decode(&req)
+	err = qtx.RotateRefreshToken(r.Context(), database.RotateRefreshTokenParams{
+		TokenHash:  storedToken.TokenHash,
+		ReplacedBy: sql.NullString{String: createdRefreshToken.TokenHash, Valid: true},
+	})
 	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	newHashedPassword, err := auth.HashPassword(req.Password)
+	// 4. Generate new access token for that user (1 hour expiry)
+	newAccessToken, err := cfg.keyring.MakeSessionJWT(user.ID, storedToken.FamilyID, time.Hour)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	updatedUser, err := cfg.db.UpdateUserEmailPassword(r.Context(), database.UpdateUserEmailPasswordParams{
-		ID: userID,
-		Email: req.Email,
-		HashedPassword: newHashedPassword,
-	})
+	err = tx.Commit()
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	resp := User{
-		ID:        updatedUser.ID,
-		CreatedAt: updatedUser.CreatedAt,
-		UpdatedAt: updatedUser.UpdatedAt,
-		Email:     updatedUser.Email,
-		IsChirpyRed: updatedUser.IsChirpyRed,
+	// 5. Return both new tokens; the old refresh token no longer works
+	resp := map[string]string{
+		"token":         newAccessToken,
+		"refresh_token": newRefreshToken,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) revokeRefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Extract refresh token from Authorization header
+	refreshToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
+	// 2. Revoke the refresh token in the database
+	revoked, err := cfg.db.RevokeRefreshToken(r.Context(), auth.HashToken(refreshToken))
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if revoked == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
+// registerRoutes adds every endpoint to mux. ServeMux panics on patterns
+// that conflict, so the test that calls this keeps them from reaching main.
+func (cfg *apiConfig) registerRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/users", cfg.createUserHandler)
+	// PUT predates PATCH and takes the same body, so it gets the same
+	// current password check and session revocation
+	mux.HandleFunc("PUT /api/users", cfg.RequireAuth(loginOnly, cfg.patchUserHandler))
+	mux.HandleFunc("PATCH /api/users", cfg.RequireAuth(auth.ScopeProfileWrite, cfg.patchUserHandler))
+	mux.HandleFunc("DELETE /api/users", cfg.RequireAuth(loginOnly, cfg.deleteUserHandler))
+	mux.HandleFunc("GET /api/users/me/export", cfg.RequireAuth(loginOnly, cfg.exportUserHandler))
+	mux.HandleFunc("POST /api/users/verify", cfg.verifyEmailHandler)
+	mux.HandleFunc("POST /api/users/email/confirm", cfg.confirmEmailChangeHandler)
+	mux.HandleFunc("POST /api/users/verify/resend", cfg.RequireAuth(loginOnly, cfg.resendVerificationHandler))
+	mux.HandleFunc("POST /api/users/2fa/setup", cfg.RequireAuth(loginOnly, cfg.setupTwoFactorHandler))
+	mux.HandleFunc("POST /api/users/2fa/enable", cfg.RequireAuth(loginOnly, cfg.enableTwoFactorHandler))
+	mux.HandleFunc("GET /api/users/{username}", cfg.getUserProfileHandler)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.RequireAuth(auth.ScopeProfileWrite, cfg.followUserHandler))
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.RequireAuth(auth.ScopeProfileWrite, cfg.unfollowUserHandler))
+	mux.HandleFunc("GET /api/users/{userID}/followers", cfg.getFollowersHandler)
+	mux.HandleFunc("GET /api/users/{userID}/following", cfg.getFollowingHandler)
+	mux.HandleFunc("GET /api/users/{userID}/likes", cfg.OptionalAuth(auth.ScopeChirpsRead, cfg.getUserLikesHandler))
+	mux.HandleFunc("GET /api/users/{userID}/mentions", cfg.OptionalAuth(auth.ScopeChirpsRead, cfg.getUserMentionsHandler))
+	mux.HandleFunc("GET /api/timeline", cfg.RequireAuth(auth.ScopeChirpsRead, cfg.timelineHandler))
+	mux.HandleFunc("POST /api/login", cfg.loginHandler)
+	mux.HandleFunc("POST /api/login/2fa", cfg.loginTwoFactorHandler)
+	mux.HandleFunc("GET /api/sessions", cfg.RequireAuth(loginOnly, cfg.getSessionsHandler))
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.RequireAuth(loginOnly, cfg.revokeSessionHandler))
+	mux.HandleFunc("POST /api/sessions/revoke-others", cfg.RequireAuth(loginOnly, cfg.revokeOtherSessionsHandler))
+	mux.HandleFunc("POST /api/tokens", cfg.RequireAuth(loginOnly, cfg.createAPITokenHandler))
+	mux.HandleFunc("GET /api/tokens", cfg.RequireAuth(loginOnly, cfg.getAPITokensHandler))
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", cfg.RequireAuth(loginOnly, cfg.revokeAPITokenHandler))
+	mux.HandleFunc("POST /api/oauth/clients", cfg.RequireAuth(loginOnly, cfg.createOAuthClientHandler))
+	mux.HandleFunc("POST /api/oauth/authorize", cfg.RequireAuth(loginOnly, cfg.authorizeOAuthHandler))
+	mux.HandleFunc("POST /api/oauth/token", cfg.oauthTokenHandler)
+	mux.HandleFunc("GET /api/oauth/authorizations", cfg.RequireAuth(loginOnly, cfg.getOAuthAuthorizationsHandler))
+	mux.HandleFunc("DELETE /api/oauth/authorizations/{clientID}", cfg.RequireAuth(loginOnly, cfg.revokeOAuthAuthorizationHandler))
+	mux.HandleFunc("POST /api/password/forgot", cfg.forgotPasswordHandler)
+	mux.HandleFunc("POST /api/password/reset", cfg.resetPasswordHandler)
+	mux.HandleFunc("POST /api/refresh", cfg.refreshTokenHandler)
+	mux.HandleFunc("POST /api/revoke", cfg.revokeRefreshTokenHandler)
+	mux.HandleFunc("POST /api/chirps", cfg.RequireAuth(auth.ScopeChirpsWrite, cfg.createChirpHandler))
+	mux.HandleFunc("GET /api/chirps", cfg.OptionalAuth(auth.ScopeChirpsRead, cfg.getAllChirpsHandler))
+	mux.HandleFunc("GET /api/chirps/search", cfg.OptionalAuth(auth.ScopeChirpsRead, cfg.searchChirpsHandler))
+	mux.HandleFunc("GET /api/chirps/scheduled", cfg.RequireAuth(auth.ScopeChirpsRead, cfg.getScheduledChirpsHandler))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/schedule", cfg.RequireAuth(auth.ScopeChirpsWrite, cfg.cancelScheduledChirpHandler))
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.OptionalAuth(auth.ScopeChirpsRead, cfg.getChirpByIdHandler))
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", cfg.RequireAuth(auth.ScopeChirpsWrite, cfg.updateChirpHandler))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.OptionalAuth(auth.ScopeChirpsRead, cfg.getChirpRevisionsHandler))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.OptionalAuth(auth.ScopeChirpsRead, cfg.getChirpThreadHandler))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", cfg.RequireAuth(auth.ScopeChirpsWrite, cfg.likeChirpHandler))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", cfg.RequireAuth(auth.ScopeChirpsWrite, cfg.unlikeChirpHandler))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.RequireAuth(auth.ScopeChirpsWrite, cfg.deleteChirpByIdHandler))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", cfg.RequireAuth(auth.ScopeChirpsWrite, cfg.restoreChirpByIdHandler))
+	mux.HandleFunc("GET /api/tags/trending", cfg.getTrendingTagsHandler)
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", cfg.OptionalAuth(auth.ScopeChirpsRead, cfg.getTagChirpsHandler))
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.polkaWebhookHandler)
+	mux.HandleFunc("GET /api/healthz", handlers.ReadinessHandler)
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.jwksHandler)
+	mux.HandleFunc("POST /admin/reset", cfg.RequireRole(roleAdmin, cfg.resetMetricsHandler))
+	mux.HandleFunc("GET /admin/metrics", cfg.RequireRole(roleAdmin, cfg.metricsHandler))
+	mux.HandleFunc("GET /admin/users", cfg.RequireRole(roleModerator, cfg.listUsersHandler))
+	mux.HandleFunc("POST /admin/users/{userID}/suspend", cfg.RequireRole(roleModerator, cfg.suspendUserHandler))
+	mux.HandleFunc("POST /admin/users/{userID}/unsuspend", cfg.RequireRole(roleModerator, cfg.unsuspendUserHandler))
+	mux.HandleFunc("POST /admin/users/{userID}/unlock", cfg.RequireRole(roleModerator, cfg.unlockUserHandler))
+	mux.HandleFunc("PUT /admin/users/{userID}/chirpy-red", cfg.RequireRole(roleAdmin, cfg.setChirpyRedHandler))
+	mux.HandleFunc("PUT /admin/users/{userID}/role", cfg.RequireRole(roleAdmin, cfg.setUserRoleHandler))
+	mux.HandleFunc("POST /admin/users/{userID}/sessions/revoke", cfg.RequireRole(roleAdmin, cfg.revokeUserSessionsHandler))
+	mux.HandleFunc("GET /admin/audit-log", cfg.RequireRole(roleAdmin, cfg.getAuditLogHandler))
+	mux.Handle("/app/", http.StripPrefix("/app/", cfg.middlewareMetricsInc(http.FileServer(http.Dir(".")))))
+	mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("./assets"))))
+}
 
 func main() {
 	godotenv.Load()
@@ -576,7 +1412,26 @@ func main() {
 	dbQueries := database.New(db)
 	platform := os.Getenv("PLATFORM")
 	secret := os.Getenv("SECRET")
+	// two-factor and email verification tokens are signed with SECRET even
+	// when access tokens use JWT_SIGNING_KEY, and an empty key would let
+	// anyone forge them
+	if secret == "" {
+		log.Fatal("SECRET must be set")
+	}
 	polkaKey := os.Getenv("POLKA_KEY")
+	editWindow := durationFromEnv("CHIRP_EDIT_WINDOW", defaultEditWindow)
+	redEditWindow := durationFromEnv("CHIRP_EDIT_WINDOW_RED", defaultRedEditWindow)
+	purgeInterval := durationFromEnv("PURGE_INTERVAL", defaultPurgeInterval)
+	deletionGrace := durationFromEnv("ACCOUNT_DELETION_GRACE", defaultDeletionGrace)
+	requireEmailVerification := os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true"
+	keyring := keyringFromEnv(secret)
+	loginThrottles := loginThrottleStoreFromEnv(dbQueries)
+
+	// without an SMTP relay, mail is written to stdout for development
+	var mail mailer.Mailer = mailer.NewLogMailer(os.Stdout)
+	if smtpAddr := os.Getenv("SMTP_ADDR"); smtpAddr != "" {
+		mail = mailer.NewSMTPMailer(smtpAddr, os.Getenv("MAIL_FROM"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+	}
 	port := 8080
 	mux := http.NewServeMux()
 	server := &http.Server{
@@ -586,26 +1441,31 @@ func main() {
 	apiConfig := &apiConfig{
 		fileserverHits: atomic.Int32{},
 		db: dbQueries,
+		authDB: dbQueries,
+		dbConn: db,
 		platform: platform,
 		secret: secret,
+		keyring: keyring,
 		polkaKey: polkaKey,
+		editWindow: editWindow,
+		redEditWindow: redEditWindow,
+		deletionGrace: deletionGrace,
+		mailer: mail,
+		requireEmailVerification: requireEmailVerification,
+		loginThrottles: loginThrottles,
+		accountLimiter: newLoginLimiter(loginThrottles, accountLoginPolicy),
+		ipLimiter: newLoginLimiter(loginThrottles, ipLoginPolicy),
+		resetEmailLimiter: lockout.New(loginThrottles, passwordResetEmailPolicy),
+		resetIPLimiter: lockout.New(loginThrottles, passwordResetIPPolicy),
+		passwordResets: make(chan string, passwordResetQueueSize),
 	}
 
-	mux.HandleFunc("POST /api/users", apiConfig.createUserHandler)
-	mux.HandleFunc("PUT /api/users", apiConfig.updateUserEmailPasswordHandler)
-	mux.HandleFunc("POST /api/login", apiConfig.loginHandler)
-	mux.HandleFunc("POST /api/refresh", apiConfig.refreshTokenHandler)
-	mux.HandleFunc("POST /api/revoke", apiConfig.revokeRefreshTokenHandler)
-	mux.HandleFunc("POST /api/chirps", apiConfig.createChirpHandler)
-	mux.HandleFunc("GET /api/chirps", apiConfig.getAllChirpsHandler)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiConfig.getChirpByIdHandler)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiConfig.deleteChirpByIdHandler)
-	mux.HandleFunc("POST /api/polka/webhooks", apiConfig.polkaWebhookHandler)
-	mux.HandleFunc("GET /api/healthz", handlers.ReadinessHandler)
-	mux.HandleFunc("POST /admin/reset", apiConfig.resetMetricsHandler)
-	mux.HandleFunc("GET /admin/metrics", apiConfig.metricsHandler)
-	mux.Handle("/app/", http.StripPrefix("/app/", apiConfig.middlewareMetricsInc(http.FileServer(http.Dir(".")))))
-    mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("./assets"))))
+	go apiConfig.runPurgeJob(purgeInterval)
+	for range passwordResetWorkers {
+		go apiConfig.runPasswordResetWorker()
+	}
+
+	apiConfig.registerRoutes(mux)
 	log.Println("Listening on port:", port)
 	log.Fatal(server.ListenAndServe())
 }
\ No newline at end of file
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/auth"
+	"github.com/kn1ghtm0nster/internal/database"
+)
+
+// loginOnly as the scope of RequireAuth admits only access tokens from a
+// login, for account management that API tokens must never reach.
+const loginOnly = ""
+
+const authRealm = "chirpy"
+
+var (
+	errInvalidToken     = errors.New("invalid or expired token")
+	errAccountSuspended = errors.New("account suspended")
+	errPendingDeletion  = errors.New("account scheduled for deletion")
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID      uuid.UUID
+	Role        string
+	IsChirpyRed bool
+	Scopes      []string
+	// SessionID is the refresh token family behind an access token. It is
+	// uuid.Nil for API tokens and for access tokens issued without one.
+	SessionID uuid.UUID
+	// APIToken is set for personal access tokens and OAuth tokens.
+	APIToken bool
+}
+
+// HasScope reports whether the principal may do what scope allows.
+func (p Principal) HasScope(scope string) bool {
+	if scope == loginOnly {
+		return !p.APIToken
+	}
+	return auth.CheckScope(p.Scopes, scope) == nil
+}
+
+// authStore is the part of the database authenticate reads, which
+// *database.Queries implements and tests can fake.
+type authStore interface {
+	UseAPIToken(ctx context.Context, tokenHash string) (database.UseAPITokenRow, error)
+	IsSessionActive(ctx context.Context, arg database.IsSessionActiveParams) (bool, error)
+	GetUserById(ctx context.Context, id uuid.UUID) (database.User, error)
+}
+
+type principalContextKey struct{}
+
+func principalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
+
+// requirePrincipal returns the principal RequireAuth put in the request's
+// context. A handler that calls it without being wrapped in RequireAuth
+// panics on its first request instead of running unauthenticated.
+func requirePrincipal(r *http.Request) Principal {
+	principal, ok := principalFromContext(r.Context())
+	if !ok {
+		panic(fmt.Sprintf("%s %s: handler needs RequireAuth", r.Method, r.URL.Path))
+	}
+	return principal
+}
+
+// optionalViewerID is the caller's user ID on routes wrapped in OptionalAuth.
+func optionalViewerID(r *http.Request) uuid.NullUUID {
+	principal, ok := principalFromContext(r.Context())
+	if !ok {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: principal.UserID, Valid: true}
+}
+
+// authenticate resolves a bearer token, which can be an access token from
+// a login or an API token. Every failure that is the token's fault is
+// errInvalidToken; suspended accounts get errAccountSuspended and accounts
+// scheduled for deletion errPendingDeletion.
+func (cfg *apiConfig) authenticate(ctx context.Context, token string) (Principal, error) {
+	var principal Principal
+
+	if auth.IsAPIToken(token) {
+		apiToken, err := cfg.authDB.UseAPIToken(ctx, auth.HashToken(token))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return Principal{}, errInvalidToken
+			}
+			return Principal{}, err
+		}
+		principal.UserID = apiToken.UserID
+		principal.Scopes = apiToken.Scopes
+		principal.APIToken = true
+	} else {
+		userID, sessionID, err := cfg.keyring.ValidateSessionJWT(token)
+		if err != nil {
+			return Principal{}, errInvalidToken
+		}
+		principal.UserID = userID
+		principal.SessionID = sessionID
+		principal.Scopes = auth.AllScopes()
+
+		// revoking a session, or every session with a password change,
+		// has to end its access tokens too and not just its refresh token
+		if sessionID != uuid.Nil {
+			active, err := cfg.authDB.IsSessionActive(ctx, database.IsSessionActiveParams{
+				UserID:   userID,
+				FamilyID: sessionID,
+			})
+			if err != nil {
+				return Principal{}, err
+			}
+			if !active {
+				return Principal{}, errInvalidToken
+			}
+		}
+	}
+
+	// a token can outlive the account it was issued for
+	user, err := cfg.authDB.GetUserById(ctx, principal.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Principal{}, errInvalidToken
+		}
+		return Principal{}, err
+	}
+	if user.SuspendedAt.Valid {
+		return Principal{}, errAccountSuspended
+	}
+	// no token, API tokens included, works during the grace period;
+	// logging in again is what cancels the deletion
+	if user.DeletionRequestedAt.Valid {
+		return Principal{}, errPendingDeletion
+	}
+	principal.Role = user.Role
+	principal.IsChirpyRed = user.IsChirpyRed
+
+	return principal, nil
+}
+
+// writeAuthChallenge answers with the WWW-Authenticate header RFC 6750
+// describes for bearer tokens. errorCode is empty when no token was sent.
+func writeAuthChallenge(w http.ResponseWriter, status int, errorCode, description, scope string) {
+	params := []string{fmt.Sprintf("realm=%q", authRealm)}
+	if errorCode != "" {
+		params = append(params, fmt.Sprintf("error=%q", errorCode))
+	}
+	if description != "" {
+		params = append(params, fmt.Sprintf("error_description=%q", description))
+	}
+	if scope != "" {
+		params = append(params, fmt.Sprintf("scope=%q", scope))
+	}
+
+	w.Header().Set("WWW-Authenticate", "Bearer "+strings.Join(params, ", "))
+	http.Error(w, http.StatusText(status), status)
+}
+
+// RequireAuth only calls next for requests with a valid bearer token that
+// has scope, and makes the caller available through requirePrincipal.
+func (cfg *apiConfig) RequireAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			writeAuthChallenge(w, http.StatusUnauthorized, "", "", "")
+			return
+		}
+
+		principal, err := cfg.authenticate(r.Context(), token)
+		if err != nil {
+			if err == errInvalidToken {
+				writeAuthChallenge(w, http.StatusUnauthorized, "invalid_token", err.Error(), "")
+				return
+			}
+			if err == errAccountSuspended {
+				http.Error(w, "Account suspended", http.StatusForbidden)
+				return
+			}
+			if err == errPendingDeletion {
+				http.Error(w, "Account is scheduled for deletion, log in again to cancel it", http.StatusForbidden)
+				return
+			}
+			log.Println("Error authenticating request:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			if scope == loginOnly {
+				writeAuthChallenge(w, http.StatusForbidden, "insufficient_scope", "API tokens can't be used here, log in instead", "")
+				return
+			}
+			writeAuthChallenge(w, http.StatusForbidden, "insufficient_scope", "", scope)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), principalContextKey{}, principal)))
+	}
+}
+
+// OptionalAuth is RequireAuth for endpoints that also serve anonymous
+// callers but personalize their response for a signed-in one. A token that
+// is invalid, lacks scope or belongs to a suspended account or one
+// scheduled for deletion is treated as no token.
+func (cfg *apiConfig) OptionalAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			next(w, r)
+			return
+		}
+
+		principal, err := cfg.authenticate(r.Context(), token)
+		if err != nil {
+			if err != errInvalidToken && err != errAccountSuspended && err != errPendingDeletion {
+				log.Println("Error authenticating request:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			next(w, r)
+			return
+		}
+		if !principal.HasScope(scope) {
+			next(w, r)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), principalContextKey{}, principal)))
+	}
+}
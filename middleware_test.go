@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/auth"
+	"github.com/kn1ghtm0nster/internal/database"
+)
+
+type fakeAuthStore struct {
+	apiTokens map[string]database.UseAPITokenRow
+	sessions  map[uuid.UUID]bool
+	users     map[uuid.UUID]database.User
+}
+
+func (s *fakeAuthStore) UseAPIToken(ctx context.Context, tokenHash string) (database.UseAPITokenRow, error) {
+	row, ok := s.apiTokens[tokenHash]
+	if !ok {
+		return database.UseAPITokenRow{}, sql.ErrNoRows
+	}
+	return row, nil
+}
+
+func (s *fakeAuthStore) IsSessionActive(ctx context.Context, arg database.IsSessionActiveParams) (bool, error) {
+	return s.sessions[arg.FamilyID], nil
+}
+
+func (s *fakeAuthStore) GetUserById(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+// authFixture holds a config whose store knows a few users, along with
+// bearer tokens for each case the middleware has to tell apart.
+type authFixture struct {
+	cfg    *apiConfig
+	tokens map[string]string
+	users  map[string]uuid.UUID
+}
+
+func newAuthFixture(t *testing.T) authFixture {
+	t.Helper()
+
+	store := &fakeAuthStore{
+		apiTokens: map[string]database.UseAPITokenRow{},
+		sessions:  map[uuid.UUID]bool{},
+		users:     map[uuid.UUID]database.User{},
+	}
+	cfg := &apiConfig{
+		authDB:  store,
+		keyring: auth.NewHMACKeyring("test-secret"),
+	}
+	f := authFixture{cfg: cfg, tokens: map[string]string{}, users: map[string]uuid.UUID{}}
+
+	addUser := func(name string, user database.User) uuid.UUID {
+		user.ID = uuid.New()
+		if user.Role == "" {
+			user.Role = roleUser
+		}
+		store.users[user.ID] = user
+		f.users[name] = user.ID
+		return user.ID
+	}
+	login := func(name string, userID uuid.UUID, active bool) {
+		sessionID := uuid.New()
+		store.sessions[sessionID] = active
+		token, err := cfg.keyring.MakeSessionJWT(userID, sessionID, time.Hour)
+		if err != nil {
+			t.Fatalf("MakeSessionJWT returned an error: %v", err)
+		}
+		f.tokens[name] = token
+	}
+	apiToken := func(name string, userID uuid.UUID, scopes ...string) {
+		token, err := auth.MakeAPIToken(auth.PersonalAccessTokenPrefix)
+		if err != nil {
+			t.Fatalf("MakeAPIToken returned an error: %v", err)
+		}
+		store.apiTokens[auth.HashToken(token)] = database.UseAPITokenRow{UserID: userID, Scopes: scopes}
+		f.tokens[name] = token
+	}
+
+	jane := addUser("jane", database.User{})
+	admin := addUser("admin", database.User{Role: roleAdmin})
+	suspended := addUser("suspended", database.User{SuspendedAt: sql.NullTime{Time: time.Now(), Valid: true}})
+	leaving := addUser("leaving", database.User{DeletionRequestedAt: sql.NullTime{Time: time.Now(), Valid: true}})
+
+	login("login", jane, true)
+	login("revoked session", jane, false)
+	login("admin login", admin, true)
+	login("suspended", suspended, true)
+	login("pending deletion", leaving, true)
+	apiToken("read token", jane, auth.ScopeChirpsRead)
+	apiToken("write token", jane, auth.ScopeChirpsWrite)
+	apiToken("admin token", admin, auth.ScopeChirpsRead, auth.ScopeChirpsWrite, auth.ScopeProfileWrite)
+	f.tokens["malformed"] = "not-a-jwt"
+
+	return f
+}
+
+// serve sends a request with the named token, or none, through wrap and
+// reports the status and the user the wrapped handler saw. A viewer that
+// isn't Valid means the handler ran anonymously or not at all.
+func (f authFixture) serve(wrap func(http.HandlerFunc) http.HandlerFunc, token string) (int, uuid.NullUUID) {
+	var viewer uuid.NullUUID
+	handler := wrap(func(w http.ResponseWriter, r *http.Request) {
+		viewer = optionalViewerID(r)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.tokens[token])
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	return rec.Code, viewer
+}
+
+func TestRequireAuth(t *testing.T) {
+	f := newAuthFixture(t)
+
+	tests := []struct {
+		name   string
+		scope  string
+		token  string
+		status int
+		user   string
+	}{
+		{"no token", auth.ScopeChirpsWrite, "", http.StatusUnauthorized, ""},
+		{"malformed token", auth.ScopeChirpsWrite, "malformed", http.StatusUnauthorized, ""},
+		{"login", auth.ScopeChirpsWrite, "login", http.StatusOK, "jane"},
+		{"login on a login-only route", loginOnly, "login", http.StatusOK, "jane"},
+		{"revoked session", auth.ScopeChirpsWrite, "revoked session", http.StatusUnauthorized, ""},
+		{"API token with the scope", auth.ScopeChirpsWrite, "write token", http.StatusOK, "jane"},
+		{"API token without the scope", auth.ScopeChirpsWrite, "read token", http.StatusForbidden, ""},
+		{"API token on a login-only route", loginOnly, "admin token", http.StatusForbidden, ""},
+		{"suspended account", auth.ScopeChirpsWrite, "suspended", http.StatusForbidden, ""},
+		{"account scheduled for deletion", auth.ScopeChirpsWrite, "pending deletion", http.StatusForbidden, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status, viewer := f.serve(func(next http.HandlerFunc) http.HandlerFunc {
+				return f.cfg.RequireAuth(tc.scope, next)
+			}, tc.token)
+
+			if status != tc.status {
+				t.Errorf("status = %d, expected %d", status, tc.status)
+			}
+			if want := f.users[tc.user]; viewer.UUID != want {
+				t.Errorf("handler saw user %v, expected %v", viewer.UUID, want)
+			}
+		})
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	f := newAuthFixture(t)
+
+	tests := []struct {
+		name  string
+		token string
+		user  string
+	}{
+		{"no token", "", ""},
+		{"malformed token", "malformed", ""},
+		{"login", "login", "jane"},
+		{"revoked session", "revoked session", ""},
+		{"API token with chirps:read", "read token", "jane"},
+		{"API token without chirps:read", "write token", ""},
+		{"suspended account", "suspended", ""},
+		{"account scheduled for deletion", "pending deletion", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status, viewer := f.serve(func(next http.HandlerFunc) http.HandlerFunc {
+				return f.cfg.OptionalAuth(auth.ScopeChirpsRead, next)
+			}, tc.token)
+
+			// a token that doesn't count is the same as none, never an error
+			if status != http.StatusOK {
+				t.Errorf("status = %d, expected %d", status, http.StatusOK)
+			}
+			if viewer.Valid != (tc.user != "") || viewer.UUID != f.users[tc.user] {
+				t.Errorf("handler saw user %v, expected %q", viewer, tc.user)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	f := newAuthFixture(t)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"user", "login", http.StatusForbidden},
+		{"admin", "admin login", http.StatusOK},
+		{"admin with an API token", "admin token", http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _ := f.serve(func(next http.HandlerFunc) http.HandlerFunc {
+				return f.cfg.RequireRole(roleAdmin, next)
+			}, tc.token)
+
+			if status != tc.status {
+				t.Errorf("status = %d, expected %d", status, tc.status)
+			}
+		})
+	}
+}
+
+func TestPrincipalHasScope(t *testing.T) {
+	login := Principal{Scopes: auth.AllScopes()}
+	readOnly := Principal{Scopes: []string{auth.ScopeChirpsRead}, APIToken: true}
+
+	tests := []struct {
+		name      string
+		principal Principal
+		scope     string
+		want      bool
+	}{
+		{"login, scoped route", login, auth.ScopeProfileWrite, true},
+		{"login, login-only route", login, loginOnly, true},
+		{"API token with the scope", readOnly, auth.ScopeChirpsRead, true},
+		{"API token without the scope", readOnly, auth.ScopeChirpsWrite, false},
+		{"API token, login-only route", readOnly, loginOnly, false},
+	}
+
+	for _, tc := range tests {
+		if got := tc.principal.HasScope(tc.scope); got != tc.want {
+			t.Errorf("%s: HasScope(%q) = %v, expected %v", tc.name, tc.scope, got, tc.want)
+		}
+	}
+}
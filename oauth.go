@@ -0,0 +1,435 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/auth"
+	"github.com/kn1ghtm0nster/internal/database"
+)
+
+const (
+	oauthAccessTokenTTL      = 30 * 24 * time.Hour
+	maxOAuthClientNameLength = 100
+	maxRedirectURIs          = 10
+)
+
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+}
+
+type OAuthClient struct {
+	ClientID     uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AuthorizeRequest carries the parameters of an OAuth authorization
+// request (RFC 6749, section 4.1.1). The consent screen sends it once the
+// user has approved the app.
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+type AuthorizeResponse struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// OAuthAuthorization is an app the user has authorized.
+type OAuthAuthorization struct {
+	ClientID     uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	Scopes       []string  `json:"scopes"`
+	AuthorizedAt time.Time `json:"authorized_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// oauthError writes an error response in the format RFC 6749 requires
+// from the token endpoint.
+func oauthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// validRedirectURI allows https, plain http only for loopback addresses,
+// and private-use schemes for native apps, which have to be reverse domain
+// names such as com.example.app (RFC 8252). Schemes a browser would run or
+// read locally are never allowed.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	case "javascript", "data", "vbscript", "file":
+		return false
+	default:
+		return strings.Contains(u.Scheme, ".")
+	}
+}
+
+func (cfg *apiConfig) createOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateOAuthClientRequest
+
+	userID := requirePrincipal(r).UserID
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxOAuthClientNameLength {
+		http.Error(w, "Name must be between 1 and 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.RedirectURIs) == 0 || len(req.RedirectURIs) > maxRedirectURIs {
+		http.Error(w, "Between 1 and 10 redirect URIs are required", http.StatusBadRequest)
+		return
+	}
+	for _, redirectURI := range req.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			http.Error(w, "Invalid redirect URI: "+redirectURI, http.StatusBadRequest)
+			return
+		}
+	}
+
+	client, err := cfg.db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		OwnerID:      userID,
+		Name:         name,
+		RedirectUris: req.RedirectURIs,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := OAuthClient{
+		ClientID:     client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		CreatedAt:    client.CreatedAt,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) authorizeOAuthHandler(w http.ResponseWriter, r *http.Request) {
+	var req AuthorizeRequest
+
+	// the route is loginOnly, so only the user themselves can approve an
+	// app, never another app
+	userID := requirePrincipal(r).UserID
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	clientID, err := uuid.Parse(req.ClientID)
+	if err != nil {
+		http.Error(w, "Unknown client", http.StatusBadRequest)
+		return
+	}
+
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unknown client", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// an exact match, so the code can only be delivered to the app
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		http.Error(w, "Redirect URI is not registered for this client", http.StatusBadRequest)
+		return
+	}
+
+	if req.ResponseType != "code" {
+		http.Error(w, "response_type must be code", http.StatusBadRequest)
+		return
+	}
+
+	// every client is public, so PKCE is what stops an intercepted code
+	// from being exchanged by someone else
+	if req.CodeChallengeMethod != auth.PKCEMethodS256 || !auth.ValidCodeChallenge(req.CodeChallenge) {
+		http.Error(w, "An S256 code_challenge is required", http.StatusBadRequest)
+		return
+	}
+
+	scopes, err := auth.ParseScopes(req.Scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.UpsertOAuthGrant(r.Context(), database.UpsertOAuthGrantParams{
+		UserID:   userID,
+		ClientID: client.ID,
+		Scopes:   scopes,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = qtx.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	redirectURI, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	query := redirectURI.Query()
+	query.Set("code", code)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	redirectURI.RawQuery = query.Encode()
+
+	resp := AuthorizeResponse{
+		RedirectURI: redirectURI.String(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// oauthTokenHandler exchanges an authorization code for an access token.
+// Like every OAuth token endpoint it takes a form-encoded body.
+func (cfg *apiConfig) oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", "Malformed request body")
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		oauthError(w, http.StatusBadRequest, "unsupported_grant_type", "Only authorization_code is supported")
+		return
+	}
+
+	clientID, err := uuid.Parse(r.PostForm.Get("client_id"))
+	if err != nil {
+		oauthError(w, http.StatusUnauthorized, "invalid_client", "Unknown client")
+		return
+	}
+
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			oauthError(w, http.StatusUnauthorized, "invalid_client", "Unknown client")
+			return
+		}
+		oauthError(w, http.StatusInternalServerError, "server_error", "Internal Server Error")
+		return
+	}
+
+	// the code is used up even when the checks below fail, so a stolen
+	// code gets a single guess at the verifier
+	code, err := cfg.db.ConsumeOAuthAuthorizationCode(r.Context(), auth.HashToken(r.PostForm.Get("code")))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "Invalid or expired code")
+			return
+		}
+		oauthError(w, http.StatusInternalServerError, "server_error", "Internal Server Error")
+		return
+	}
+
+	if code.ClientID != client.ID ||
+		code.RedirectUri != r.PostForm.Get("redirect_uri") ||
+		!auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		oauthError(w, http.StatusBadRequest, "invalid_grant", "Invalid or expired code")
+		return
+	}
+
+	accessToken, err := auth.MakeAPIToken(auth.OAuthAccessTokenPrefix)
+	if err != nil {
+		oauthError(w, http.StatusInternalServerError, "server_error", "Internal Server Error")
+		return
+	}
+
+	_, err = cfg.db.CreateAPIToken(r.Context(), database.CreateAPITokenParams{
+		UserID:    code.UserID,
+		ClientID:  uuid.NullUUID{UUID: client.ID, Valid: true},
+		Name:      client.Name,
+		TokenHash: auth.HashToken(accessToken),
+		Scopes:    code.Scopes,
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(oauthAccessTokenTTL), Valid: true},
+	})
+	if err != nil {
+		oauthError(w, http.StatusInternalServerError, "server_error", "Internal Server Error")
+		return
+	}
+
+	resp := OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oauthAccessTokenTTL.Seconds()),
+		Scope:       strings.Join(code.Scopes, " "),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) getOAuthAuthorizationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requirePrincipal(r).UserID
+
+	grants, err := cfg.db.ListOAuthGrantsForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]OAuthAuthorization, len(grants))
+	for i, grant := range grants {
+		resp[i] = OAuthAuthorization{
+			ClientID:     grant.ClientID,
+			Name:         grant.Name,
+			Scopes:       grant.Scopes,
+			AuthorizedAt: grant.CreatedAt,
+			UpdatedAt:    grant.UpdatedAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// revokeOAuthAuthorizationHandler removes an app's access: its grant, the
+// tokens it holds and any codes it hasn't exchanged yet.
+func (cfg *apiConfig) revokeOAuthAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID := requirePrincipal(r).UserID
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	deleted, err := qtx.DeleteOAuthGrant(r.Context(), database.DeleteOAuthGrantParams{
+		UserID:   userID,
+		ClientID: clientID,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Authorization not found", http.StatusNotFound)
+		return
+	}
+
+	err = qtx.RevokeClientTokensForUser(r.Context(), database.RevokeClientTokensForUserParams{
+		UserID:   userID,
+		ClientID: uuid.NullUUID{UUID: clientID, Valid: true},
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = qtx.InvalidateOAuthAuthorizationCodes(r.Context(), database.InvalidateOAuthAuthorizationCodesParams{
+		UserID:   userID,
+		ClientID: clientID,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestValidRedirectURI(t *testing.T) {
+	tests := []struct {
+		uri   string
+		valid bool
+	}{
+		{"https://app.example.com/callback", true},
+		{"https:///callback", false},
+		{"https://app.example.com/callback#token", false},
+		{"http://localhost:8080/callback", true},
+		{"http://127.0.0.1/callback", true},
+		{"http://[::1]:3000/callback", true},
+		{"http://app.example.com/callback", false},
+		{"com.example.app:/callback", true},
+		{"com.example.app://callback", true},
+		{"myapp://callback", false},
+		{"javascript:alert(document.cookie)", false},
+		{"JavaScript:alert(1)", false},
+		{"data:text/html,<script>alert(1)</script>", false},
+		{"vbscript:msgbox(1)", false},
+		{"file:///etc/passwd", false},
+		{"/callback", false},
+		{"", false},
+	}
+
+	for _, tc := range tests {
+		got := validRedirectURI(tc.uri)
+		if got != tc.valid {
+			t.Errorf("validRedirectURI(%q) = %v, expected %v", tc.uri, got, tc.valid)
+		}
+	}
+}
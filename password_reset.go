@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/kn1ghtm0nster/internal/auth"
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/internal/lockout"
+	"github.com/kn1ghtm0nster/internal/mailer"
+)
+
+// passwordResetTTL must match the expiry set by CreatePasswordResetToken.
+const passwordResetTTL = 30 * time.Minute
+
+// Reset emails are sent by a fixed number of workers, so a flood of
+// requests queues up or is turned away instead of opening an SMTP session
+// each.
+const (
+	passwordResetWorkers   = 2
+	passwordResetQueueSize = 100
+)
+
+// passwordResetEmailPolicy allows a couple of emails to an address, then
+// makes each further one wait longer. Requests count whether or not the
+// address has an account.
+var passwordResetEmailPolicy = lockout.Policy{
+	FreeAttempts: 2,
+	BaseDelay:    time.Minute,
+	MaxDelay:     15 * time.Minute,
+	Window:       loginThrottleWindow,
+}
+
+var passwordResetIPPolicy = lockout.Policy{
+	FreeAttempts: 10,
+	BaseDelay:    time.Minute,
+	MaxDelay:     15 * time.Minute,
+	Window:       loginThrottleWindow,
+}
+
+func passwordResetThrottleKey(email string) string {
+	return "reset:" + hashedEmail(email)
+}
+
+func passwordResetIPThrottleKey(ip string) string {
+	return "reset-ip:" + ip
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (cfg *apiConfig) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	attempts := []throttledAttempt{
+		{cfg.resetEmailLimiter, passwordResetThrottleKey(req.Email)},
+		{cfg.resetIPLimiter, passwordResetIPThrottleKey(clientIP(r))},
+	}
+
+	wait, err := beginAttempts(r.Context(), attempts)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
+
+	// the lookup and the mail happen after the response so neither the
+	// status nor the response time says whether the email has an account
+	select {
+	case cfg.passwordResets <- req.Email:
+	default:
+		http.Error(w, "Too many requests, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// runPasswordResetWorker sends the reset emails queued by
+// forgotPasswordHandler, one at a time.
+func (cfg *apiConfig) runPasswordResetWorker() {
+	for email := range cfg.passwordResets {
+		cfg.sendPasswordReset(email)
+	}
+}
+
+func (cfg *apiConfig) sendPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	user, err := cfg.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("Error looking up user for password reset:", err)
+		}
+		return
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Println("Error creating password reset token:", err)
+		return
+	}
+
+	// only the hash is stored, so a leaked table can't be used to reset
+	// anyone's password
+	err = cfg.db.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+	})
+	if err != nil {
+		log.Println("Error storing password reset token:", err)
+		return
+	}
+
+	err = cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Send this token with your new password to POST /api/password/reset:\n\n%s\n\nIt expires in %s. If you didn't ask to reset your password you can ignore this email.\n",
+			token, passwordResetTTL,
+		),
+	})
+	if err != nil {
+		log.Println("Error sending password reset email:", err)
+	}
+}
+
+func (cfg *apiConfig) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if req.Password == "" {
+		http.Error(w, "Password is required", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	// marking the token used in the same statement that checks it means
+	// two concurrent resets can't both succeed with it
+	userID, err := qtx.ConsumePasswordResetToken(r.Context(), auth.HashToken(req.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = qtx.UpdateUser(r.Context(), database.UpdateUserParams{
+		ID:             userID,
+		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// any other reset links that are still out there are no longer needed
+	err = qtx.InvalidatePasswordResetTokensForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = qtx.RevokeAllRefreshTokensForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// personal access tokens and app authorizations don't belong to a
+	// session, and could have been made by whoever had the account
+	err = qtx.RevokeAllAPITokensForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/kn1ghtm0nster/utils"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+
+	usernameUniqueIndex = "users_username_lower_idx"
+)
+
+// UserProfile is the public view of a user. It is served without
+// authentication, so it must never carry the email address.
+type UserProfile struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	JoinedAt    time.Time `json:"joined_at"`
+	ChirpCount  int64     `json:"chirp_count"`
+}
+
+func validateProfile(displayName, bio string) error {
+	if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+		return errors.New("display_name is too long")
+	}
+	if utf8.RuneCountInString(bio) > maxBioLength {
+		return errors.New("bio is too long")
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err was caused by a duplicate value in
+// the given unique constraint or index.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+func (cfg *apiConfig) getUserProfileHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+
+	// anything that can't be a username can't have a profile either
+	if utils.ValidateUsername(username) == utils.ErrInvalidUsername {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	profile, err := cfg.db.GetUserProfileByUsername(r.Context(), username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := UserProfile{
+		ID:          profile.ID,
+		Username:    profile.Username.String,
+		DisplayName: profile.DisplayName,
+		Bio:         profile.Bio,
+		JoinedAt:    profile.CreatedAt,
+		ChirpCount:  profile.ChirpCount,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
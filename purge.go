@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runPurgeJob hard-deletes data whose grace period has run out. It runs
+// once at startup and then on every tick, so a restart never delays a
+// purge by more than one interval.
+func (cfg *apiConfig) runPurgeJob(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.purgeExpired(context.Background())
+		<-ticker.C
+	}
+}
+
+// purgeStep is one kind of data purgeExpired cleans up. Name completes
+// "Purged %d ..." in the logs.
+type purgeStep struct {
+	name  string
+	purge func(ctx context.Context) (int64, error)
+}
+
+func (cfg *apiConfig) purgeExpired(ctx context.Context) {
+	steps := []purgeStep{
+		{"deleted chirps", func(ctx context.Context) (int64, error) {
+			return cfg.db.PurgeDeletedChirps(ctx, time.Now().Add(-chirpRestoreWindow))
+		}},
+		{"deleted users", func(ctx context.Context) (int64, error) {
+			return cfg.db.PurgeDeletedUsers(ctx, time.Now().Add(-cfg.deletionGrace))
+		}},
+		{"expired password reset tokens", cfg.db.PurgeExpiredPasswordResetTokens},
+		{"expired OAuth authorization codes", cfg.db.PurgeExpiredOAuthAuthorizationCodes},
+		{"expired login throttles", func(ctx context.Context) (int64, error) {
+			return cfg.loginThrottles.Purge(ctx, time.Now().Add(-loginThrottleWindow))
+		}},
+	}
+
+	// a failing step is retried on the next tick without holding up the
+	// ones after it
+	for _, step := range steps {
+		purged, err := step.purge(ctx)
+		if err != nil {
+			log.Printf("Error purging %s: %v", step.name, err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("Purged %d %s", purged, step.name)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/utils"
+)
+
+type UpdateChirpRequest struct {
+	Body string `json:"body"`
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func (cfg *apiConfig) updateChirpHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdateChirpRequest
+
+	chirpID := r.PathValue("chirpID")
+
+	parsedId, err := uuid.Parse(chirpID)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	principal := requirePrincipal(r)
+	userID := principal.UserID
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	// edits go through the same cleaning and length rules as new chirps
+	cleanedBody := utils.CleanProfanity(req.Body)
+
+	if len(cleanedBody) > 140 {
+		http.Error(w, "Chirp is too long", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	// lock the row so concurrent edits can't both record the same
+	// previous body as their revision
+	chirp, err := qtx.GetChirpByIdForUpdate(r.Context(), database.GetChirpByIdForUpdateParams{
+		ID:       parsedId,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if chirp.UserID != userID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	editWindow := cfg.editWindow
+	if principal.IsChirpyRed {
+		editWindow = cfg.redEditWindow
+	}
+	if time.Since(chirp.CreatedAt) > editWindow {
+		http.Error(w, "Edit window has expired", http.StatusForbidden)
+		return
+	}
+
+	// a plain rechirp has no text of its own
+	if cleanedBody == "" && !chirp.RechirpOfID.Valid {
+		http.Error(w, "Body is required", http.StatusBadRequest)
+		return
+	}
+
+	updatedChirp := chirp
+	if cleanedBody != chirp.Body {
+		_, err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+			ChirpID: chirp.ID,
+			Body:    chirp.Body,
+		})
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		updatedChirp, err = qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+			ID:   chirp.ID,
+			Body: cleanedBody,
+		})
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		err = indexChirp(r.Context(), qtx, updatedChirp)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	chirps, err := cfg.chirpResponses(r.Context(), []database.Chirp{updatedChirp}, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	resp := chirps[0]
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) getChirpRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID := r.PathValue("chirpID")
+
+	parsedId, err := uuid.Parse(chirpID)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	_, err = cfg.db.GetChirpById(r.Context(), database.GetChirpByIdParams{
+		ID:       parsedId,
+		ViewerID: optionalViewerID(r),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	revisions, err := cfg.db.ListChirpRevisions(r.Context(), parsedId)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]ChirpRevision, len(revisions))
+	for i, revision := range revisions {
+		resp[i] = ChirpRevision{
+			ID:         revision.ID,
+			Body:       revision.Body,
+			ReplacedAt: revision.CreatedAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
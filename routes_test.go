@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// ServeMux panics when two patterns overlap without one being more
+// specific, which would otherwise only show up when the server starts.
+func TestRegisterRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	cfg := &apiConfig{}
+	cfg.registerRoutes(mux)
+
+	tests := []struct {
+		method  string
+		path    string
+		pattern string
+	}{
+		{"GET", "/api/chirps/scheduled", "GET /api/chirps/scheduled"},
+		{"GET", "/api/chirps/abc", "GET /api/chirps/{chirpID}"},
+		{"DELETE", "/api/chirps/abc/schedule", "DELETE /api/chirps/{chirpID}/schedule"},
+		{"DELETE", "/api/chirps/scheduled/likes", "DELETE /api/chirps/{chirpID}/likes"},
+		{"DELETE", "/api/chirps/abc", "DELETE /api/chirps/{chirpID}"},
+		{"GET", "/api/chirps/search", "GET /api/chirps/search"},
+		{"POST", "/api/users/email/confirm", "POST /api/users/email/confirm"},
+	}
+
+	for _, tc := range tests {
+		_, pattern := mux.Handler(httptest.NewRequest(tc.method, tc.path, nil))
+		if pattern != tc.pattern {
+			t.Errorf("%s %s is routed to %q, expected %q", tc.method, tc.path, pattern, tc.pattern)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/database"
+)
+
+func (cfg *apiConfig) getScheduledChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requirePrincipal(r).UserID
+
+	chirps, err := cfg.db.ListScheduledChirps(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := cfg.chirpResponses(r.Context(), chirps, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) cancelScheduledChirpHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID := r.PathValue("chirpID")
+
+	parsedId, err := uuid.Parse(chirpID)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID := requirePrincipal(r).UserID
+
+	// a chirp that was never published is removed outright instead of
+	// being tombstoned; once publish_at has passed this no longer matches
+	// and the normal delete endpoint applies
+	deleted, err := cfg.db.CancelScheduledChirp(r.Context(), database.CancelScheduledChirpParams{
+		ID:     parsedId,
+		UserID: userID,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if deleted == 0 {
+		http.Error(w, "Scheduled chirp not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,21 @@
+-- name: CreateAuditLogEntry :exec
+INSERT INTO admin_audit_log (id, actor_id, action, target_user_id, details, ip_address, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW()
+);
+
+-- name: ListAuditLogEntries :many
+SELECT * FROM admin_audit_log
+WHERE (sqlc.narg(target_user_id)::uuid IS NULL OR target_user_id = sqlc.narg(target_user_id))
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (created_at, id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,50 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (id, user_id, client_id, name, token_hash, scopes, created_at, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW(),
+    $6
+)
+RETURNING *;
+
+-- name: UseAPIToken :one
+UPDATE api_tokens
+SET last_used_at = NOW()
+WHERE token_hash = $1
+    AND revoked_at IS NULL
+    AND (expires_at IS NULL OR expires_at > NOW())
+RETURNING user_id, scopes;
+
+-- name: ListPersonalAccessTokens :many
+SELECT * FROM api_tokens
+WHERE user_id = $1
+    AND client_id IS NULL
+    AND revoked_at IS NULL
+    AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at DESC;
+
+-- name: RevokePersonalAccessToken :execrows
+UPDATE api_tokens
+SET revoked_at = NOW()
+WHERE id = $1
+    AND user_id = $2
+    AND client_id IS NULL
+    AND revoked_at IS NULL;
+
+-- name: RevokeClientTokensForUser :exec
+UPDATE api_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1
+    AND client_id = $2
+    AND revoked_at IS NULL;
+
+-- name: RevokeAllAPITokensForUser :exec
+UPDATE api_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1
+    AND revoked_at IS NULL;
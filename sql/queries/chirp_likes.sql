@@ -0,0 +1,31 @@
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: ListLikedChirpIds :many
+SELECT chirp_id FROM chirp_likes
+WHERE user_id = sqlc.arg(user_id)
+    AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: ListChirpsLikedByUser :many
+SELECT sqlc.embed(chirps), chirp_likes.created_at AS liked_at
+FROM chirp_likes
+INNER JOIN chirps ON chirps.id = chirp_likes.chirp_id
+WHERE chirp_likes.user_id = sqlc.arg(user_id)
+    AND chirps.deleted_at IS NULL
+    AND (chirps.publish_at IS NULL OR chirps.publish_at <= NOW() OR chirps.user_id = sqlc.narg(viewer_id))
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (chirp_likes.created_at, chirp_likes.chirp_id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY chirp_likes.created_at DESC, chirp_likes.chirp_id DESC
+LIMIT sqlc.arg(page_size);
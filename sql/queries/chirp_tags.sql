@@ -0,0 +1,55 @@
+-- name: CreateChirpTags :exec
+INSERT INTO chirp_tags (chirp_id, tag)
+SELECT sqlc.arg(chirp_id), unnest(sqlc.arg(tags)::text[])
+ON CONFLICT (chirp_id, tag) DO NOTHING;
+
+-- name: DeleteChirpTags :exec
+DELETE FROM chirp_tags WHERE chirp_id = $1;
+
+-- name: CreateChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id)
+SELECT sqlc.arg(chirp_id), users.id
+FROM users
+WHERE LOWER(users.email) = ANY(sqlc.arg(mentions)::text[])
+    OR LOWER(users.username) = ANY(sqlc.arg(mentions)::text[])
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions WHERE chirp_id = $1;
+
+-- name: ListChirpsByTag :many
+SELECT chirps.* FROM chirps
+INNER JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+WHERE chirp_tags.tag = sqlc.arg(tag)
+    AND chirps.deleted_at IS NULL
+    AND (chirps.publish_at IS NULL OR chirps.publish_at <= NOW() OR chirps.user_id = sqlc.narg(viewer_id))
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (chirps.created_at, chirps.id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsMentioningUser :many
+SELECT chirps.* FROM chirps
+INNER JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE chirp_mentions.user_id = sqlc.arg(user_id)
+    AND chirps.deleted_at IS NULL
+    AND (chirps.publish_at IS NULL OR chirps.publish_at <= NOW() OR chirps.user_id = sqlc.narg(viewer_id))
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (chirps.created_at, chirps.id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListTrendingTags :many
+SELECT chirp_tags.tag, COUNT(*) AS chirp_count
+FROM chirp_tags
+INNER JOIN chirps ON chirps.id = chirp_tags.chirp_id
+WHERE chirps.created_at >= sqlc.arg(since)::timestamptz
+    AND chirps.created_at <= NOW()
+    AND chirps.deleted_at IS NULL
+GROUP BY chirp_tags.tag
+ORDER BY chirp_count DESC, chirp_tags.tag ASC
+LIMIT sqlc.arg(page_size);
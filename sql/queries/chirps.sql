@@ -1,24 +1,136 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_id, rechirp_of_id, publish_at)
 VALUES (
     gen_random_uuid(),
+    COALESCE(sqlc.narg(publish_at)::timestamptz, NOW()),
     NOW(),
-    NOW(),
-    $1,
-    $2
+    sqlc.arg(body),
+    sqlc.arg(user_id),
+    sqlc.narg(parent_id),
+    sqlc.narg(rechirp_of_id),
+    sqlc.narg(publish_at)
 )
 RETURNING *;
 
--- name: GetAllChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+    AND (publish_at IS NULL OR publish_at <= NOW() OR user_id = sqlc.narg(viewer_id))
+    AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id))
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (created_at, id) > (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
 
--- name: GetChirpsByAuthorId :many
+-- name: ListChirpsDesc :many
 SELECT * FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
+WHERE deleted_at IS NULL
+    AND (publish_at IS NULL OR publish_at <= NOW() OR user_id = sqlc.narg(viewer_id))
+    AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id))
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (created_at, id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
 
 -- name: GetChirpById :one
-SELECT * FROM chirps WHERE id = $1;
+SELECT * FROM chirps
+WHERE id = sqlc.arg(id)
+    AND deleted_at IS NULL
+    AND (publish_at IS NULL OR publish_at <= NOW() OR user_id = sqlc.narg(viewer_id));
+
+-- name: GetChirpByIdForUpdate :one
+SELECT * FROM chirps
+WHERE id = sqlc.arg(id)
+    AND deleted_at IS NULL
+    AND (publish_at IS NULL OR publish_at <= NOW() OR user_id = sqlc.narg(viewer_id))
+FOR UPDATE;
 
--- name: DeleteChirpById :exec
-DELETE FROM chirps WHERE id = $1 AND user_id = $2;
\ No newline at end of file
+-- name: GetDeletedChirpById :one
+SELECT * FROM chirps WHERE id = $1 AND deleted_at IS NOT NULL;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetChirpsByIds :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+    AND deleted_at IS NULL
+    AND (publish_at IS NULL OR publish_at <= NOW() OR user_id = sqlc.narg(viewer_id));
+
+-- name: GetChirpThread :many
+WITH RECURSIVE thread (id, depth) AS (
+    SELECT chirps.id, 0
+    FROM chirps
+    WHERE chirps.id = sqlc.arg(root_id)
+        AND (chirps.publish_at IS NULL OR chirps.publish_at <= NOW() OR chirps.user_id = sqlc.narg(viewer_id))
+    UNION ALL
+    SELECT replies.id, thread.depth + 1
+    FROM chirps replies
+    INNER JOIN thread ON replies.parent_id = thread.id
+    WHERE thread.depth < sqlc.arg(max_depth)::int
+        AND (replies.publish_at IS NULL OR replies.publish_at <= NOW() OR replies.user_id = sqlc.narg(viewer_id))
+)
+SELECT sqlc.embed(chirps), thread.depth::int AS depth
+FROM thread
+INNER JOIN chirps ON chirps.id = thread.id
+ORDER BY thread.depth ASC, chirps.created_at ASC, chirps.id ASC;
+
+-- name: SoftDeleteChirpById :exec
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL;
+
+-- name: RestoreChirpById :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = sqlc.arg(id)
+    AND user_id = sqlc.arg(user_id)
+    AND deleted_at > sqlc.arg(deleted_after)::timestamptz
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < sqlc.arg(deleted_before)::timestamptz;
+
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps), ts_rank(chirps.search, websearch_to_tsquery('english', sqlc.arg(query))) AS rank
+FROM chirps
+WHERE chirps.search @@ websearch_to_tsquery('english', sqlc.arg(query))
+    AND chirps.deleted_at IS NULL
+    AND (chirps.publish_at IS NULL OR chirps.publish_at <= NOW() OR chirps.user_id = sqlc.narg(viewer_id))
+    AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id))
+    AND (
+        sqlc.narg(after_rank)::real IS NULL
+        OR (ts_rank(chirps.search, websearch_to_tsquery('english', sqlc.arg(query))), chirps.id) < (sqlc.narg(after_rank), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY rank DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1
+    AND deleted_at IS NULL
+    AND publish_at > NOW()
+ORDER BY publish_at ASC, id ASC;
+
+-- name: CancelScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND publish_at > NOW();
+
+-- name: ListChirpsForExport :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (created_at, id) > (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
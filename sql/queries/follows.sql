@@ -0,0 +1,45 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: ListFollowers :many
+SELECT * FROM follows
+WHERE followee_id = sqlc.arg(user_id)
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (created_at, follower_id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListFollowing :many
+SELECT * FROM follows
+WHERE follower_id = sqlc.arg(user_id)
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (created_at, followee_id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetTimeline :many
+SELECT chirps.* FROM chirps
+INNER JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg(user_id)
+    AND chirps.deleted_at IS NULL
+    AND (chirps.publish_at IS NULL OR chirps.publish_at <= NOW())
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (chirps.created_at, chirps.id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,42 @@
+-- name: DeleteLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = $1;
+
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE key = $1;
+
+-- name: PurgeLoginThrottles :execrows
+DELETE FROM login_throttles
+WHERE last_failure_at < $1
+    AND (locked_until IS NULL OR locked_until < $1);
+
+-- name: RecordLoginAttempt :one
+INSERT INTO login_throttles (key, failures, last_failure_at, locked_until)
+VALUES (
+    sqlc.arg(key),
+    1,
+    sqlc.arg(attempted_at)::timestamptz,
+    sqlc.arg(attempted_at)::timestamptz + (sqlc.arg(delays_ms)::bigint[])[1] * INTERVAL '1 millisecond'
+)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < sqlc.arg(window_start)::timestamptz THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = sqlc.arg(attempted_at)::timestamptz,
+    locked_until = sqlc.arg(attempted_at)::timestamptz + (sqlc.arg(delays_ms)::bigint[])[LEAST(
+        CASE
+            WHEN login_throttles.last_failure_at < sqlc.arg(window_start)::timestamptz THEN 1
+            ELSE login_throttles.failures + 1
+        END,
+        cardinality(sqlc.arg(delays_ms)::bigint[])
+    )] * INTERVAL '1 millisecond'
+WHERE login_throttles.locked_until IS NULL
+    OR login_throttles.locked_until <= sqlc.arg(attempted_at)::timestamptz
+RETURNING failures, locked_until;
+
+-- name: RefundLoginFailure :exec
+UPDATE login_throttles
+SET failures = GREATEST(failures - 1, 0)
+WHERE key = $1;
@@ -0,0 +1,65 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, owner_id, name, redirect_uris, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients WHERE id = $1;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW(),
+    NOW() + INTERVAL '10 minutes'
+);
+
+-- name: ConsumeOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE code_hash = $1
+    AND used_at IS NULL
+    AND expires_at > NOW()
+RETURNING client_id, user_id, redirect_uri, scopes, code_challenge;
+
+-- name: InvalidateOAuthAuthorizationCodes :exec
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE user_id = $1
+    AND client_id = $2
+    AND used_at IS NULL;
+
+-- name: PurgeExpiredOAuthAuthorizationCodes :execrows
+DELETE FROM oauth_authorization_codes
+WHERE expires_at < NOW();
+
+-- name: UpsertOAuthGrant :exec
+INSERT INTO oauth_grants (user_id, client_id, scopes, created_at, updated_at)
+VALUES ($1, $2, $3, NOW(), NOW())
+ON CONFLICT (user_id, client_id) DO UPDATE
+SET scopes = ARRAY(
+        SELECT DISTINCT unnest(oauth_grants.scopes || EXCLUDED.scopes) ORDER BY 1
+    ),
+    updated_at = NOW();
+
+-- name: ListOAuthGrantsForUser :many
+SELECT oauth_grants.client_id, oauth_clients.name, oauth_grants.scopes, oauth_grants.created_at, oauth_grants.updated_at
+FROM oauth_grants
+JOIN oauth_clients ON oauth_clients.id = oauth_grants.client_id
+WHERE oauth_grants.user_id = $1
+ORDER BY oauth_grants.updated_at DESC;
+
+-- name: DeleteOAuthGrant :execrows
+DELETE FROM oauth_grants
+WHERE user_id = $1 AND client_id = $2;
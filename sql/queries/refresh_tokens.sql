@@ -1,32 +1,106 @@
 -- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (
     user_id,
-    token,
+    token_hash,
+    family_id,
+    user_agent,
+    ip_address,
     expires_at,
     created_at,
-    updated_at
+    updated_at,
+    last_used_at
 ) VALUES (
     $1,
     $2,
+    $3,
+    $4,
+    $5,
     NOW() + INTERVAL '60 days',
     NOW(),
+    NOW(),
     NOW()
 
 ) RETURNING *;
 
 
--- name: GetUserFromRefreshToken :one
-SELECT u.* 
-FROM users u
-INNER JOIN 
-    refresh_tokens rt ON u.id = rt.user_id
-WHERE rt.token = $1
+-- name: RevokeRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = COALESCE(revoked_at, NOW()),
+    updated_at = NOW()
+WHERE token_hash = $1;
+
+
+-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1
+    AND revoked_at IS NULL;
+
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1
+FOR UPDATE;
+
+
+-- name: RotateRefreshToken :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW(),
+    replaced_by = $2
+WHERE token_hash = $1;
+
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE family_id = $1
+    AND revoked_at IS NULL;
+
+
+-- name: ListSessionsForUser :many
+SELECT
+    rt.family_id,
+    rt.user_agent,
+    rt.ip_address,
+    rt.last_used_at,
+    rt.expires_at,
+    (
+        SELECT MIN(first.created_at) FROM refresh_tokens first
+        WHERE first.family_id = rt.family_id
+    )::timestamptz AS started_at
+FROM refresh_tokens rt
+WHERE rt.user_id = $1
     AND rt.revoked_at IS NULL
-    AND rt.expires_at > NOW();
+    AND rt.expires_at > NOW()
+ORDER BY rt.last_used_at DESC;
 
 
--- name: RevokeRefreshToken :exec
+-- name: RevokeSession :execrows
 UPDATE refresh_tokens
 SET revoked_at = NOW(),
     updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE user_id = $1
+    AND family_id = $2
+    AND revoked_at IS NULL;
+
+
+-- name: RevokeOtherSessions :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1
+    AND family_id <> $2
+    AND revoked_at IS NULL;
+
+
+-- name: IsSessionActive :one
+SELECT EXISTS (
+    SELECT 1 FROM refresh_tokens
+    WHERE user_id = $1
+        AND family_id = $2
+        AND revoked_at IS NULL
+        AND expires_at > NOW()
+);
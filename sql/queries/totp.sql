@@ -0,0 +1,39 @@
+-- name: UpsertUserTOTP :exec
+INSERT INTO user_totp (user_id, secret, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    created_at = NOW(),
+    last_step = NULL
+WHERE user_totp.enabled_at IS NULL;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp WHERE user_id = $1;
+
+-- name: EnableUserTOTP :execrows
+UPDATE user_totp
+SET enabled_at = NOW(),
+    last_step = sqlc.arg(step)::bigint
+WHERE user_id = sqlc.arg(user_id)
+    AND enabled_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_totp
+SET last_step = sqlc.arg(step)::bigint
+WHERE user_id = sqlc.arg(user_id)
+    AND enabled_at IS NOT NULL
+    AND (last_step IS NULL OR last_step < sqlc.arg(step)::bigint);
+
+-- name: CreateRecoveryCodes :exec
+INSERT INTO recovery_codes (code_hash, user_id, created_at)
+SELECT unnest(sqlc.arg(code_hashes)::text[]), sqlc.arg(user_id), NOW();
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1
+    AND code_hash = $2
+    AND used_at IS NULL;
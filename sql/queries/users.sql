@@ -1,23 +1,17 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username, display_name, bio)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
 RETURNING *;
 
--- name: UpdateUserEmailPassword :one
-UPDATE users 
-SET email = $2,
-    hashed_password = $3,
-    updated_at = NOW()
-WHERE id = $1
-RETURNING *;
-
-
 -- name: DeleteAllUsers :exec
 DELETE FROM users;
 
@@ -29,4 +23,111 @@ UPDATE users
 SET is_chirpy_red = TRUE,
     updated_at = NOW()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: GetUserById :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: GetUserProfileByUsername :one
+SELECT
+    users.id,
+    users.created_at,
+    users.username,
+    users.display_name,
+    users.bio,
+    (
+        SELECT COUNT(*) FROM chirps
+        WHERE chirps.user_id = users.id
+            AND chirps.deleted_at IS NULL
+            AND (chirps.publish_at IS NULL OR chirps.publish_at <= NOW())
+    ) AS chirp_count
+FROM users
+WHERE LOWER(users.username) = LOWER(sqlc.arg(username)::text);
+
+-- name: UpdateUser :one
+UPDATE users
+SET hashed_password = COALESCE(sqlc.narg(hashed_password), hashed_password),
+    username = COALESCE(sqlc.narg(username), username),
+    display_name = COALESCE(sqlc.narg(display_name), display_name),
+    bio = COALESCE(sqlc.narg(bio), bio),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: RequestUserDeletion :one
+UPDATE users
+SET deletion_requested_at = COALESCE(deletion_requested_at, NOW()),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+    AND deletion_requested_at IS NOT NULL;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deletion_requested_at IS NOT NULL
+    AND deletion_requested_at < sqlc.arg(requested_before)::timestamptz;
+
+-- name: ChangeUserEmail :execrows
+UPDATE users
+SET email = sqlc.arg(new_email),
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+    AND email = sqlc.arg(email);
+
+-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+    AND email = $2
+    AND email_verified_at IS NULL;
+
+-- name: ListUsers :many
+SELECT * FROM users
+WHERE (
+        sqlc.narg(query)::text IS NULL
+        OR strpos(LOWER(email), LOWER(sqlc.narg(query))) > 0
+        OR strpos(LOWER(username), LOWER(sqlc.narg(query))) > 0
+    )
+    AND (sqlc.narg(role)::text IS NULL OR role = sqlc.narg(role))
+    AND (
+        sqlc.narg(after_created_at)::timestamptz IS NULL
+        OR (created_at, id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid)
+    )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = COALESCE(suspended_at, NOW()),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserChirpyRed :one
+UPDATE users
+SET is_chirpy_red = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- deleting a chirp deletes every reply underneath it
+ALTER TABLE chirps
+ADD COLUMN parent_id UUID NULL REFERENCES chirps(id) ON DELETE CASCADE;
+
+CREATE INDEX chirps_parent_id_idx ON chirps (parent_id);
+
+-- +goose Down
+DROP INDEX chirps_parent_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN parent_id;
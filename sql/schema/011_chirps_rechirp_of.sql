@@ -0,0 +1,13 @@
+-- +goose Up
+-- rechirp_of_id deliberately has no foreign key: a repost outlives the
+-- chirp it points at and renders it as unavailable once it is gone
+ALTER TABLE chirps
+ADD COLUMN rechirp_of_id UUID NULL;
+
+CREATE INDEX chirps_rechirp_of_id_idx ON chirps (rechirp_of_id);
+
+-- +goose Down
+DROP INDEX chirps_rechirp_of_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN rechirp_of_id;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- scheduled chirps are inserted with created_at set to their publish time
+-- so they slot into feeds at the right position once visible; publish_at
+-- stays NULL for chirps that were posted immediately
+ALTER TABLE chirps
+ADD COLUMN publish_at TIMESTAMPTZ NULL;
+
+CREATE INDEX chirps_user_id_publish_at_idx ON chirps (user_id, publish_at) WHERE publish_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_user_id_publish_at_idx;
+
+ALTER TABLE chirps
+DROP COLUMN publish_at;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- only a SHA-256 digest of each refresh token is kept from now on; the
+-- digests of existing tokens are computed in place so sessions survive
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex'),
+    replaced_by = encode(sha256(convert_to(replaced_by, 'UTF8')), 'hex');
+
+-- +goose Down
+-- the plaintext tokens can't be recovered, so every session is revoked
+UPDATE refresh_tokens
+SET revoked_at = COALESCE(revoked_at, NOW());
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
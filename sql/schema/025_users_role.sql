@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin')),
+ADD COLUMN suspended_at TIMESTAMPTZ NULL;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN suspended_at,
+DROP COLUMN role;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- no foreign keys, so entries outlive the accounts they mention
+CREATE TABLE admin_audit_log (
+    id UUID PRIMARY KEY,
+    actor_id UUID NOT NULL,
+    action TEXT NOT NULL,
+    target_user_id UUID NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    ip_address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX admin_audit_log_created_at_idx ON admin_audit_log (created_at, id);
+CREATE INDEX admin_audit_log_target_user_id_idx ON admin_audit_log (target_user_id);
+
+-- +goose Down
+DROP TABLE admin_audit_log;
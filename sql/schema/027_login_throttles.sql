@@ -0,0 +1,13 @@
+-- +goose Up
+-- failed login counters, keyed by account or client address
+CREATE TABLE login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ NULL
+);
+
+CREATE INDEX login_throttles_last_failure_at_idx ON login_throttles (last_failure_at);
+
+-- +goose Down
+DROP TABLE login_throttles;
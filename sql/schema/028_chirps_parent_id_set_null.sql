@@ -0,0 +1,11 @@
+-- +goose Up
+-- purging a chirp detaches its replies instead of deleting them, since
+-- they belong to other users who never deleted them
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_parent_id_fkey,
+ADD CONSTRAINT chirps_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES chirps(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_parent_id_fkey,
+ADD CONSTRAINT chirps_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES chirps(id) ON DELETE CASCADE;
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/utils"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+)
+
+type TrendingTag struct {
+	Tag        string `json:"tag"`
+	ChirpCount int64  `json:"chirp_count"`
+}
+
+// indexChirp replaces the stored hashtags and mentions of a chirp with the
+// ones found in its current body. Mentions that don't match a user are
+// dropped by the insert itself.
+func indexChirp(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	err := q.DeleteChirpTags(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+
+	err = q.DeleteChirpMentions(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+
+	err = q.CreateChirpTags(ctx, database.CreateChirpTagsParams{
+		ChirpID: chirp.ID,
+		Tags:    utils.ExtractHashtags(chirp.Body),
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.CreateChirpMentions(ctx, database.CreateChirpMentionsParams{
+		ChirpID:  chirp.ID,
+		Mentions: utils.ExtractMentions(chirp.Body),
+	})
+}
+
+func (cfg *apiConfig) getTagChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	if tag == "" {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	limit, cursor, err := parsePageParams(r, utils.CursorKindTime)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	afterCreatedAt, afterID := cursorArgs(cursor)
+	viewerID := optionalViewerID(r)
+
+	// fetch one extra row so we know whether there is another page
+	chirps, err := cfg.db.ListChirpsByTag(r.Context(), database.ListChirpsByTagParams{
+		Tag:            tag,
+		ViewerID:       viewerID,
+		AfterCreatedAt: afterCreatedAt,
+		AfterID:        afterID,
+		PageSize:       int32(limit + 1),
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	cfg.writeChirpPage(w, r, chirps, limit, viewerID)
+}
+
+func (cfg *apiConfig) getUserMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	limit, cursor, err := parsePageParams(r, utils.CursorKindTime)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	_, err = cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	afterCreatedAt, afterID := cursorArgs(cursor)
+	viewerID := optionalViewerID(r)
+
+	// fetch one extra row so we know whether there is another page
+	chirps, err := cfg.db.ListChirpsMentioningUser(r.Context(), database.ListChirpsMentioningUserParams{
+		UserID:         userID,
+		ViewerID:       viewerID,
+		AfterCreatedAt: afterCreatedAt,
+		AfterID:        afterID,
+		PageSize:       int32(limit + 1),
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	cfg.writeChirpPage(w, r, chirps, limit, viewerID)
+}
+
+func (cfg *apiConfig) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > maxTrendingWindow {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	limit := defaultTrendingLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := utils.ParseLimit(raw)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	// the window slides with every request; only chirps that are visible
+	// to everyone count towards a tag
+	tags, err := cfg.db.ListTrendingTags(r.Context(), database.ListTrendingTagsParams{
+		Since:    time.Now().Add(-window),
+		PageSize: int32(limit),
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]TrendingTag, len(tags))
+	for i, tag := range tags {
+		resp[i] = TrendingTag{
+			Tag:        tag.Tag,
+			ChirpCount: tag.ChirpCount,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeChirpPage trims a page fetched with one extra row, sets next_cursor
+// when there is more to read and writes the page.
+func (cfg *apiConfig) writeChirpPage(w http.ResponseWriter, r *http.Request, chirps []database.Chirp, limit int, viewerID uuid.NullUUID) {
+	resp := ChirpPage{}
+
+	if len(chirps) > limit {
+		chirps = chirps[:limit]
+		last := chirps[len(chirps)-1]
+		resp.NextCursor = utils.EncodeCursor(utils.Cursor{
+			Kind:      utils.CursorKindTime,
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	var err error
+	resp.Chirps, err = cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/kn1ghtm0nster/internal/auth"
+	"github.com/kn1ghtm0nster/internal/database"
+)
+
+const (
+	mfaTokenTTL       = 5 * time.Minute
+	recoveryCodeCount = 10
+	totpIssuer        = "Chirpy"
+)
+
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type EnableTwoFactorRequest struct {
+	Code string `json:"code"`
+}
+
+type EnableTwoFactorResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// LoginTwoFactorRequest carries either a code from the authenticator app
+// or one of the recovery codes handed out when 2FA was enabled.
+type LoginTwoFactorRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (cfg *apiConfig) setupTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requirePrincipal(r).UserID
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	totp, err := cfg.db.GetUserTOTP(r.Context(), userID)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err == nil && totp.EnabledAt.Valid {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// running setup again before enabling replaces the pending secret
+	err = cfg.db.UpsertUserTOTP(r.Context(), database.UpsertUserTOTPParams{
+		UserID: userID,
+		Secret: secret,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(secret, totpIssuer, user.Email),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) enableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var req EnableTwoFactorRequest
+
+	userID := requirePrincipal(r).UserID
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	totp, err := cfg.db.GetUserTOTP(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Two-factor authentication has not been set up", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if totp.EnabledAt.Valid {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	// confirming with a code proves the authenticator app was set up
+	// correctly before we start requiring it
+	step, ok := auth.ValidateTOTP(totp.Secret, req.Code, time.Now())
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	codeHashes := make([]string, len(codes))
+	for i, code := range codes {
+		codeHashes[i] = auth.HashToken(auth.NormalizeRecoveryCode(code))
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	enabled, err := qtx.EnableUserTOTP(r.Context(), database.EnableUserTOTPParams{
+		Step:   step,
+		UserID: userID,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if enabled == 0 {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	err = qtx.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = qtx.CreateRecoveryCodes(r.Context(), database.CreateRecoveryCodesParams{
+		CodeHashes: codeHashes,
+		UserID:     userID,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// this is the only time the recovery codes are shown
+	resp := EnableTwoFactorResponse{
+		RecoveryCodes: codes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) loginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var req LoginTwoFactorRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := auth.ValidateMFAToken(req.MFAToken, cfg.secret)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// an MFA token lasts long enough to guess a lot of six digit codes,
+	// so they are throttled per account like passwords
+	attempts := []throttledAttempt{
+		{cfg.accountLimiter, twoFactorThrottleKey(userID)},
+		{cfg.ipLimiter, ipThrottleKey(clientIP(r))},
+	}
+
+	// the attempt is counted as a failure before it is tried, so a burst of
+	// requests can't all get past the check before any of them fails
+	wait, err := beginAttempts(r.Context(), attempts)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	totp, err := cfg.db.GetUserTOTP(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var accepted int64
+	switch {
+	case req.Code != "":
+		step, ok := auth.ValidateTOTP(totp.Secret, req.Code, time.Now())
+		if !ok {
+			http.Error(w, "Invalid code", http.StatusUnauthorized)
+			return
+		}
+
+		// only moves forward, so each code works once even though it
+		// stays valid for the whole step
+		accepted, err = cfg.db.UseTOTPStep(r.Context(), database.UseTOTPStepParams{
+			Step:   step,
+			UserID: userID,
+		})
+	case req.RecoveryCode != "":
+		accepted, err = cfg.db.UseRecoveryCode(r.Context(), database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(req.RecoveryCode)),
+		})
+	default:
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if accepted == 0 {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	err = cfg.accountLimiter.Reset(r.Context(), twoFactorThrottleKey(userID))
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = cfg.ipLimiter.Refund(r.Context(), ipThrottleKey(clientIP(r)))
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	cfg.completeLogin(w, r, user)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kn1ghtm0nster/internal/auth"
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/internal/mailer"
+	"github.com/kn1ghtm0nster/utils"
+)
+
+const emailUniqueConstraint = "users_email_key"
+
+// PatchUserRequest only changes the fields that are sent. CurrentPassword
+// is required when changing the email or the password. A new email only
+// takes effect once it is confirmed from that address.
+type PatchUserRequest struct {
+	Email           *string `json:"email"`
+	Password        *string `json:"password"`
+	CurrentPassword string  `json:"current_password"`
+	Username        *string `json:"username"`
+	DisplayName     *string `json:"display_name"`
+	Bio             *string `json:"bio"`
+}
+
+// handleDuplicateSignup answers a signup for an email that already has an
+// account the way a new signup is answered, so the response can't be used
+// to find out who is registered. The owner is told by mail instead.
+func (cfg *apiConfig) handleDuplicateSignup(w http.ResponseWriter, r *http.Request, req CreateUserRequest, username sql.NullString) {
+	// the email index is checked first, so a taken username has to be
+	// looked for here to get the 409 a new email would get
+	if username.Valid {
+		_, err := cfg.db.GetUserProfileByUsername(r.Context(), username.String)
+		if err == nil {
+			http.Error(w, "Username is already taken", http.StatusConflict)
+			return
+		}
+		if err != sql.ErrNoRows {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// sent before responding, like the verification email of a real signup
+	err := cfg.mailer.Send(r.Context(), mailer.Message{
+		To:      req.Email,
+		Subject: "Your Chirpy account",
+		Body:    "Someone tried to sign up for Chirpy with this email address, which already has an account.\n\nIf it was you, log in instead, or reset your password with POST /api/password/forgot. Otherwise you can ignore this email.\n",
+	})
+	if err != nil {
+		log.Println("Error sending duplicate signup email:", err)
+	}
+
+	// looks like a new account, but nothing was stored
+	now := time.Now().UTC()
+	resp := userResponse(database.User{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Email:       req.Email,
+		Username:    username,
+		DisplayName: req.DisplayName,
+		Bio:         req.Bio,
+		Role:        roleUser,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (cfg *apiConfig) patchUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req PatchUserRequest
+
+	principal := requirePrincipal(r)
+	userID := principal.UserID
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	params := database.UpdateUserParams{ID: userID}
+
+	// sending the current email again is not a change and doesn't need
+	// the password
+	var newEmail string
+	if req.Email != nil && *req.Email != user.Email {
+		newEmail = strings.TrimSpace(*req.Email)
+		if newEmail == "" {
+			http.Error(w, "Email is required", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Password != nil {
+		if *req.Password == "" {
+			http.Error(w, "Password is required", http.StatusBadRequest)
+			return
+		}
+		hashedPassword, err := auth.HashPassword(*req.Password)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		params.HashedPassword = sql.NullString{String: hashedPassword, Valid: true}
+	}
+
+	if req.Username != nil {
+		err = utils.ValidateUsername(*req.Username)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		params.Username = sql.NullString{String: *req.Username, Valid: true}
+	}
+	if req.DisplayName != nil {
+		params.DisplayName = sql.NullString{String: *req.DisplayName, Valid: true}
+	}
+	if req.Bio != nil {
+		params.Bio = sql.NullString{String: *req.Bio, Valid: true}
+	}
+
+	err = validateProfile(params.DisplayName.String, params.Bio.String)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// email and password are what an attacker holding a stolen access
+	// token would change to take the account over
+	if newEmail != "" || params.HashedPassword.Valid {
+		// profile:write covers the public profile, not the credentials
+		if principal.APIToken {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if req.CurrentPassword == "" {
+			http.Error(w, "Current password is required", http.StatusBadRequest)
+			return
+		}
+		match, err := auth.CheckPasswordHash(req.CurrentPassword, user.HashedPassword)
+		if err != nil || !match {
+			http.Error(w, "Current password is incorrect", http.StatusForbidden)
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	updatedUser, err := qtx.UpdateUser(r.Context(), params)
+	if err != nil {
+		if isUniqueViolation(err, usernameUniqueIndex) {
+			http.Error(w, "Username is already taken", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// sign every session out, access tokens included since they are
+	// checked against their session, and revoke every API and OAuth token
+	if params.HashedPassword.Valid {
+		err = qtx.RevokeAllRefreshTokensForUser(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		err = qtx.RevokeAllAPITokensForUser(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// answered the same whether or not the address is taken, so it can't
+	// be used to find out who is registered
+	if newEmail != "" {
+		err = cfg.sendEmailChange(r.Context(), updatedUser, newEmail)
+		if err != nil {
+			log.Println("Error sending email change confirmation:", err)
+		}
+	}
+
+	resp := userResponse(updatedUser)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
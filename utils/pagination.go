@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Cursor kinds tell apart pages ordered by creation time from pages
+// ordered by relevance, so a cursor from one can't be fed to the other.
+const (
+	CursorKindTime = "time"
+	CursorKindRank = "rank"
+)
+
+// Cursor marks the last row of a page. Clients only ever see it as an
+// opaque string, so the fields can change without breaking the API.
+// Rank is only set for CursorKindRank cursors.
+type Cursor struct {
+	Kind      string    `json:"k"`
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Rank      float32   `json:"r,omitempty"`
+}
+
+func EncodeCursor(cursor Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor rejects cursors that aren't of the given kind.
+func DecodeCursor(raw, kind string) (Cursor, error) {
+	var cursor Cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+
+	err = json.Unmarshal(data, &cursor)
+	if err != nil || cursor.ID == uuid.Nil || cursor.Kind != kind {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// ParseLimit reads a page size from a query string value, falling back to
+// DefaultPageSize when empty and clamping to MaxPageSize.
+func ParseLimit(raw string) (int, error) {
+	if raw == "" {
+		return DefaultPageSize, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid limit")
+	}
+
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	return limit, nil
+}
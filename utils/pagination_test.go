@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDecodeCursor_RoundTrip(t *testing.T) {
+	cursor := Cursor{
+		Kind:      CursorKindTime,
+		CreatedAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	got, err := DecodeCursor(EncodeCursor(cursor), CursorKindTime)
+	if err != nil {
+		t.Fatalf("DecodeCursor returned an error: %v", err)
+	}
+	if !got.CreatedAt.Equal(cursor.CreatedAt) || got.ID != cursor.ID {
+		t.Fatalf("DecodeCursor = %+v, expected %+v", got, cursor)
+	}
+}
+
+func TestDecodeCursor_WrongKind(t *testing.T) {
+	search := EncodeCursor(Cursor{Kind: CursorKindRank, ID: uuid.New(), Rank: 0.5})
+	list := EncodeCursor(Cursor{Kind: CursorKindTime, ID: uuid.New(), CreatedAt: time.Now()})
+	unmarked := EncodeCursor(Cursor{ID: uuid.New(), CreatedAt: time.Now()})
+
+	tests := []struct {
+		raw  string
+		kind string
+	}{
+		{search, CursorKindTime},
+		{list, CursorKindRank},
+		{unmarked, CursorKindTime},
+		{unmarked, CursorKindRank},
+	}
+
+	for _, tc := range tests {
+		_, err := DecodeCursor(tc.raw, tc.kind)
+		if err == nil {
+			t.Errorf("DecodeCursor(%q, %q) accepted a cursor of another kind", tc.raw, tc.kind)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+const maxTagLength = 50
+
+// ExtractHashtags returns the distinct, lowercased #hashtags in a chirp in
+// the order they first appear. Tags are made of letters, digits and
+// underscores and must contain at least one letter, so punctuation that
+// follows a tag ("#go!", "#go's", "(#go)") is not part of it.
+func ExtractHashtags(chirp string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+
+	for _, word := range strings.Fields(chirp) {
+		word = strings.TrimLeft(word, `([{"'`)
+		if !strings.HasPrefix(word, "#") {
+			continue
+		}
+
+		tag := strings.ToLower(takeWhile(word[1:], isTagRune))
+		if tag == "" || len(tag) > maxTagLength || !strings.ContainsFunc(tag, unicode.IsLetter) {
+			continue
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ExtractMentions returns the distinct, lowercased @mentions in a chirp in
+// the order they first appear. A mention is either an email address
+// ("@jane@example.com") or a handle ("@jane"); trailing sentence
+// punctuation is dropped in both cases.
+func ExtractMentions(chirp string) []string {
+	mentions := []string{}
+	seen := map[string]bool{}
+
+	for _, word := range strings.Fields(chirp) {
+		word = strings.TrimLeft(word, `([{"'`)
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+
+		mention := strings.ToLower(takeWhile(word[1:], isMentionRune))
+		// an email can contain dots and dashes, but never end with them
+		mention = strings.TrimRight(mention, ".-")
+		if !validMention(mention) {
+			continue
+		}
+
+		if !seen[mention] {
+			seen[mention] = true
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions
+}
+
+func takeWhile(s string, keep func(rune) bool) string {
+	for i, r := range s {
+		if !keep(r) {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isMentionRune(r rune) bool {
+	return isTagRune(r) || strings.ContainsRune("@.+-", r)
+}
+
+func validMention(mention string) bool {
+	local, domain, isEmail := strings.Cut(mention, "@")
+	if !isEmail {
+		return mention != "" && !strings.ContainsAny(mention, "@.+-")
+	}
+	return local != "" && strings.Contains(domain, ".") && !strings.ContainsAny(domain, "@+") &&
+		!strings.HasPrefix(domain, ".")
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		chirp    string
+		expected []string
+	}{
+		{"no tags here", []string{}},
+		{"learning #Go today", []string{"go"}},
+		{"end of a sentence #golang.", []string{"golang"}},
+		{"excited #chirpy! #chirpy?", []string{"chirpy"}},
+		{"(#wrapped) and \"#quoted\"", []string{"wrapped", "quoted"}},
+		{"possessive #chirpy's launch", []string{"chirpy"}},
+		{"snake #go_lang and #2024goals", []string{"go_lang", "2024goals"}},
+		{"numbers only #2024 and bare # sign", []string{}},
+		{"tabs\t#one\nnewline #two", []string{"one", "two"}},
+		{"not a tag: foo#bar", []string{}},
+	}
+
+	for _, tc := range tests {
+		got := ExtractHashtags(tc.chirp)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("ExtractHashtags(%q) = %v, expected %v", tc.chirp, got, tc.expected)
+		}
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		chirp    string
+		expected []string
+	}{
+		{"no mentions here", []string{}},
+		{"hi @Jane", []string{"jane"}},
+		{"thanks @jane.", []string{"jane"}},
+		{"ping @jane@example.com.", []string{"jane@example.com"}},
+		{"ping @Jane.Doe+chirpy@Example.co.uk!", []string{"jane.doe+chirpy@example.co.uk"}},
+		{"(@jane) and @jane, again", []string{"jane"}},
+		{"bad @jane@nodot and @@ and @", []string{}},
+		{"email without at jane@example.com", []string{}},
+	}
+
+	for _, tc := range tests {
+		got := ExtractMentions(tc.chirp)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("ExtractMentions(%q) = %v, expected %v", tc.chirp, got, tc.expected)
+		}
+	}
+}
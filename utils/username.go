@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{3,30}$`)
+
+// reservedUsernames can't be registered because they clash with routes,
+// could be mistaken for staff accounts or already mean something in a
+// mention.
+var reservedUsernames = map[string]bool{
+	"2fa":       true,
+	"about":     true,
+	"admin":     true,
+	"api":       true,
+	"app":       true,
+	"assets":    true,
+	"chirpy":    true,
+	"everyone":  true,
+	"help":      true,
+	"here":      true,
+	"login":     true,
+	"logout":    true,
+	"moderator": true,
+	"null":      true,
+	"root":      true,
+	"settings":  true,
+	"signup":    true,
+	"staff":     true,
+	"support":   true,
+	"system":    true,
+	"undefined": true,
+	"verify":    true,
+}
+
+var (
+	ErrInvalidUsername  = errors.New("username must be 3-30 letters, digits or underscores")
+	ErrReservedUsername = errors.New("username is reserved")
+)
+
+// ValidateUsername checks a username against the allowed charset and the
+// reserved list. Reserved names are matched case-insensitively, the same
+// way uniqueness is enforced in the database.
+func ValidateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return ErrInvalidUsername
+	}
+	if reservedUsernames[strings.ToLower(username)] {
+		return ErrReservedUsername
+	}
+	return nil
+}
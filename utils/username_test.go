@@ -0,0 +1,33 @@
+package utils
+
+import "testing"
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		expected error
+	}{
+		{"jane", nil},
+		{"Jane_Doe_2024", nil},
+		{"abc", nil},
+		{"abcdefghijklmnopqrstuvwxyz1234", nil},
+		{"ab", ErrInvalidUsername},
+		{"abcdefghijklmnopqrstuvwxyz12345", ErrInvalidUsername},
+		{"jane.doe", ErrInvalidUsername},
+		{"jane-doe", ErrInvalidUsername},
+		{"jane doe", ErrInvalidUsername},
+		{"jané", ErrInvalidUsername},
+		{"", ErrInvalidUsername},
+		{"admin", ErrReservedUsername},
+		{"Admin", ErrReservedUsername},
+		{"API", ErrReservedUsername},
+		{"2fa", ErrReservedUsername},
+	}
+
+	for _, tc := range tests {
+		got := ValidateUsername(tc.username)
+		if got != tc.expected {
+			t.Errorf("ValidateUsername(%q) = %v, expected %v", tc.username, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kn1ghtm0nster/internal/auth"
+	"github.com/kn1ghtm0nster/internal/database"
+	"github.com/kn1ghtm0nster/internal/mailer"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token"`
+}
+
+// sendVerificationEmail mails the user a token for their current address.
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, user database.User) error {
+	token, err := auth.MakeEmailVerificationToken(user.ID, user.Email, cfg.secret, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf(
+			"Send this token to POST /api/users/verify to confirm your email address:\n\n%s\n\nIt expires in %s.\n",
+			token, emailVerificationTTL,
+		),
+	})
+}
+
+// sendEmailChange mails newEmail a token that moves the user's account to
+// it. When newEmail already has an account, its owner is told instead, and
+// nothing in the response shows which of the two happened.
+func (cfg *apiConfig) sendEmailChange(ctx context.Context, user database.User, newEmail string) error {
+	_, err := cfg.db.GetUserByEmail(ctx, newEmail)
+	if err == nil {
+		return cfg.mailer.Send(ctx, mailer.Message{
+			To:      newEmail,
+			Subject: "Your Chirpy account",
+			Body:    "Someone tried to move another Chirpy account to this email address, which already has an account.\n\nYour account hasn't changed, you can ignore this email.\n",
+		})
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	token, err := auth.MakeEmailChangeToken(user.ID, user.Email, newEmail, cfg.secret, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new Chirpy email address",
+		Body: fmt.Sprintf(
+			"Send this token to POST /api/users/email/confirm to change your Chirpy email address to this one:\n\n%s\n\nIt expires in %s. If you didn't ask for this, you can ignore this email.\n",
+			token, emailVerificationTTL,
+		),
+	})
+}
+
+func (cfg *apiConfig) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID, email, err := auth.ValidateEmailVerificationToken(req.Token, cfg.secret)
+	if err != nil {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	// the update only matches while the address is unverified and still
+	// the one the token was issued for, which makes each token single-use
+	verified, err := cfg.db.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		ID:    userID,
+		Email: email,
+	})
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if verified == 0 {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requirePrincipal(r).UserID
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+
+	err = cfg.sendVerificationEmail(r.Context(), user)
+	if err != nil {
+		http.Error(w, "Could not send verification email", http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// confirmEmailChangeHandler applies an email change once the token mailed to
+// the new address comes back. Like a password change, it signs every
+// session out and revokes every API and OAuth token.
+func (cfg *apiConfig) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var req ConfirmEmailChangeRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID, email, newEmail, err := auth.ValidateEmailChangeToken(req.Token, cfg.secret)
+	if err != nil {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.db.WithTx(tx)
+
+	// the update only matches while the account still has the address the
+	// change was requested from, which makes each token single-use
+	changed, err := qtx.ChangeUserEmail(r.Context(), database.ChangeUserEmailParams{
+		NewEmail: newEmail,
+		ID:       userID,
+		Email:    email,
+	})
+	if err != nil {
+		// only whoever reads mail at newEmail gets this far
+		if isUniqueViolation(err, emailUniqueConstraint) {
+			http.Error(w, "Email is already in use", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if changed == 0 {
+		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+		return
+	}
+
+	err = qtx.RevokeAllRefreshTokensForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	err = qtx.RevokeAllAPITokensForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}